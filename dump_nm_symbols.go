@@ -2,18 +2,28 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"debug/elf"
+	"debug/pe"
+	"debug/plan9obj"
 	"encoding/binary"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qeedquan/go-media/debug/pemapfile"
 )
 
 var (
-	bin = flag.Bool("b", false, "binary mode")
-	end = flag.String("e", "little", "endianess for binary mode")
+	bin  = flag.Bool("b", false, "binary mode")
+	end  = flag.String("e", "little", "endianess for binary mode")
+	maps = flag.String("m", "", "load bases from a /proc/<pid>/maps snapshot or dump-memmap output")
 )
 
 func main() {
@@ -22,36 +32,45 @@ func main() {
 
 	flag.Usage = usage
 	flag.Parse()
-	if flag.NArg() < 1 {
-		usage()
-	}
 
 	var (
 		r    io.Reader
-		syms []sym
-		err  error
+		args []string
 	)
-	switch flag.NArg() {
-	case 2:
-		fd, err := os.Open(flag.Arg(0))
-		ck(err)
-		defer fd.Close()
-
-		r = fd
-		syms, err = getsym(flag.Arg(1))
-	case 1:
+	switch {
+	case flag.NArg() == 1:
+		r = os.Stdin
+		args = flag.Args()
+	case flag.NArg() >= 2:
 		r = os.Stdin
-		syms, err = getsym(flag.Arg(0))
+		if flag.Arg(0) != "-" {
+			fd, err := os.Open(flag.Arg(0))
+			ck(err)
+			defer fd.Close()
+			r = fd
+		}
+		args = flag.Args()[1:]
 	default:
 		usage()
 	}
-	ck(err)
 
-	dump(r, syms)
+	var mods []*module
+	for _, arg := range args {
+		m, err := loadmodule(arg)
+		ck(err)
+		mods = append(mods, m)
+	}
+	if *maps != "" {
+		ck(rebase(*maps, mods))
+	}
+
+	dump(r, mods)
 }
 
 func usage() {
-	fmt.Fprintln(os.Stderr, "usage: dump_nm_symbols [options] <bin file> <nm file>")
+	fmt.Fprintln(os.Stderr, "usage: dump_nm_symbols [options] [<bin file> | -] <symbol file[@base]> ...")
+	fmt.Fprintln(os.Stderr, "symbol file can be nm output, an elf, a pe, an msvc map file or a plan 9 a.out")
+	fmt.Fprintln(os.Stderr, "base is the address the module was loaded at, overriding -m")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -68,9 +87,75 @@ type sym struct {
 	start uint64
 	end   uint64
 	size  uint64
+	bound uint64
 }
 
+// getsym loads the symbols from whatever kind of file it is given,
+// symbols without a size extend to the next symbol or the end of their section
 func getsym(name string) (syms []sym, err error) {
+	var magic [4]byte
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	io.ReadFull(f, magic[:])
+	f.Close()
+
+	switch {
+	case string(magic[:]) == elf.ELFMAG:
+		syms, err = elfsyms(name)
+	case string(magic[:2]) == "MZ":
+		syms, err = pesyms(name)
+	case strings.EqualFold(filepath.Ext(name), ".map"):
+		syms, err = mapsyms(name)
+	default:
+		syms, err = plan9syms(name)
+		if err != nil {
+			syms, err = nmsyms(name)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	sort.Slice(syms, func(i, j int) bool {
+		return syms[i].start < syms[j].start
+	})
+
+	// walk backwards keeping track of the closest higher start, the last
+	// symbol has none and ends at its section bound or covers only itself
+	next := uint64(0)
+	for i := len(syms) - 1; i >= 0; i-- {
+		y := &syms[i]
+		if i+1 < len(syms) && syms[i+1].start > y.start {
+			next = syms[i+1].start
+		}
+		if y.size != 0 {
+			continue
+		}
+		y.end = y.start
+		if next > y.start {
+			y.end = next - 1
+		} else if y.bound > y.start {
+			y.end = y.bound - 1
+		}
+		if b := y.bound; b > y.start && y.end >= b {
+			y.end = b - 1
+		}
+		y.size = y.end - y.start + 1
+	}
+
+	return
+}
+
+func sized(y sym) sym {
+	if y.size != 0 {
+		y.end = y.start + y.size - 1
+	}
+	return y
+}
+
+func nmsyms(name string) (syms []sym, err error) {
 	f, err := os.Open(name)
 	if err != nil {
 		return
@@ -82,32 +167,503 @@ func getsym(name string) (syms []sym, err error) {
 		var y sym
 
 		line := s.Text()
-		n, err := fmt.Sscanf(line, "%v %c %s", &y.start, &y.typ, &y.name)
+		n, err := fmt.Sscanf(line, "%x %c %s", &y.start, &y.typ, &y.name)
 		if n != 3 || err != nil {
 			continue
 		}
 		syms = append(syms, y)
 	}
+	return syms, s.Err()
+}
 
-	sort.Slice(syms, func(i, j int) bool {
-		return syms[i].start < syms[j].start
-	})
+// elftype picks the nm letter for an elf symbol
+func elftype(f *elf.File, y *elf.Symbol) int {
+	var c int
+	switch {
+	case y.Section == elf.SHN_ABS:
+		c = 'a'
+	case y.Section == elf.SHN_COMMON:
+		c = 'c'
+	case int(y.Section) >= len(f.Sections):
+		c = '?'
+	default:
+		s := f.Sections[y.Section]
+		switch {
+		case s.Flags&elf.SHF_EXECINSTR != 0:
+			c = 't'
+		case s.Type == elf.SHT_NOBITS:
+			c = 'b'
+		case s.Flags&elf.SHF_WRITE != 0:
+			c = 'd'
+		case s.Flags&elf.SHF_ALLOC != 0:
+			c = 'r'
+		default:
+			c = 'n'
+		}
+	}
+	switch elf.ST_BIND(y.Info) {
+	case elf.STB_WEAK:
+		c = 'W'
+		if elf.ST_TYPE(y.Info) == elf.STT_OBJECT {
+			c = 'V'
+		}
+	case elf.STB_GLOBAL:
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+	}
+	return c
+}
+
+func elfsyms(name string) ([]sym, error) {
+	f, err := elf.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ys, err := f.Symbols()
+	if err != nil {
+		ys, err = f.DynamicSymbols()
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	for i := 0; i < len(syms)-1; i++ {
-		syms[i].end = syms[i].start
-		for j := i + 1; j < len(syms)-1; j++ {
-			if syms[j].start > syms[i].start {
-				syms[i].end = syms[j].start - 1
-				break
+	var syms []sym
+	for i := range ys {
+		y := &ys[i]
+		switch elf.ST_TYPE(y.Info) {
+		case elf.STT_SECTION, elf.STT_FILE:
+			continue
+		}
+		if y.Section == elf.SHN_UNDEF || y.Name == "" {
+			continue
+		}
+		var bound uint64
+		if int(y.Section) < len(f.Sections) {
+			s := f.Sections[y.Section]
+			bound = s.Addr + s.Size
+		}
+		syms = append(syms, sized(sym{elftype(f, y), y.Name, y.Value, 0, y.Size, bound}))
+	}
+	return syms, nil
+}
+
+const (
+	IMAGE_SYM_CLASS_EXTERNAL = 2
+	IMAGE_SYM_CLASS_STATIC   = 3
+)
+
+func petype(s *pe.Section) int {
+	switch {
+	case s.Characteristics&(pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE) != 0:
+		return 'T'
+	case s.Characteristics&pe.IMAGE_SCN_CNT_UNINITIALIZED_DATA != 0:
+		return 'B'
+	case s.Characteristics&pe.IMAGE_SCN_MEM_WRITE != 0:
+		return 'D'
+	}
+	return 'R'
+}
+
+// pesyms uses the coff symbols if there are any and the export table otherwise
+func pesyms(name string) ([]sym, error) {
+	f, err := pe.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var base uint64
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		base = uint64(h.ImageBase)
+	case *pe.OptionalHeader64:
+		base = h.ImageBase
+	}
+
+	var syms []sym
+	for _, y := range f.Symbols {
+		if y.SectionNumber <= 0 || int(y.SectionNumber) > len(f.Sections) {
+			continue
+		}
+		s := f.Sections[y.SectionNumber-1]
+		if y.StorageClass == IMAGE_SYM_CLASS_STATIC && y.Value == 0 && y.Name == s.Name {
+			continue
+		}
+		c := petype(s)
+		if y.StorageClass != IMAGE_SYM_CLASS_EXTERNAL {
+			c += 'a' - 'A'
+		}
+		start := base + uint64(s.VirtualAddress)
+		syms = append(syms, sym{c, y.Name, start + uint64(y.Value), 0, 0, start + uint64(s.VirtualSize)})
+	}
+	if len(syms) > 0 {
+		return syms, nil
+	}
+	return peexports(f, base)
+}
+
+func peexports(f *pe.File, base uint64) ([]sym, error) {
+	var dd pe.DataDirectory
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if h.NumberOfRvaAndSizes > 0 {
+			dd = h.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+		}
+	case *pe.OptionalHeader64:
+		if h.NumberOfRvaAndSizes > 0 {
+			dd = h.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+		}
+	}
+	if dd.VirtualAddress == 0 {
+		return nil, fmt.Errorf("no coff symbols or exports")
+	}
+
+	rva := func(addr uint32) (*pe.Section, []byte) {
+		for _, s := range f.Sections {
+			if s.VirtualAddress <= addr && addr < s.VirtualAddress+max(s.VirtualSize, s.Size) {
+				data, err := s.Data()
+				if err != nil || addr-s.VirtualAddress >= uint32(len(data)) {
+					return s, nil
+				}
+				return s, data[addr-s.VirtualAddress:]
 			}
 		}
-		syms[i].size = syms[i].end - syms[i].start + 1
+		return nil, nil
 	}
 
-	return
+	_, d := rva(dd.VirtualAddress)
+	if len(d) < 40 {
+		return nil, fmt.Errorf("bad export directory")
+	}
+	le := binary.LittleEndian
+	nfuncs := le.Uint32(d[20:])
+	nnames := le.Uint32(d[24:])
+	_, funcs := rva(le.Uint32(d[28:]))
+	_, names := rva(le.Uint32(d[32:]))
+	_, ords := rva(le.Uint32(d[36:]))
+
+	var syms []sym
+	for i := uint32(0); i < nnames && 4*i+4 <= uint32(len(names)) && 2*i+2 <= uint32(len(ords)); i++ {
+		ord := uint32(le.Uint16(ords[2*i:]))
+		if ord >= nfuncs || 4*ord+4 > uint32(len(funcs)) {
+			continue
+		}
+		addr := le.Uint32(funcs[4*ord:])
+		// forwarders point back into the export directory
+		if dd.VirtualAddress <= addr && addr < dd.VirtualAddress+dd.Size {
+			continue
+		}
+		_, n := rva(le.Uint32(names[4*i:]))
+		if j := bytes.IndexByte(n, 0); j >= 0 {
+			n = n[:j]
+		}
+		s, _ := rva(addr)
+		if s == nil {
+			continue
+		}
+		syms = append(syms, sym{petype(s), string(n), base + uint64(addr), 0, 0, base + uint64(s.VirtualAddress) + uint64(s.VirtualSize)})
+	}
+	return syms, nil
+}
+
+func mapsyms(name string) ([]sym, error) {
+	mf, err := pemapfile.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var syms []sym
+	for _, y := range mf.Symbols {
+		c := 'T'
+		if y.Section < len(mf.Sections) {
+			n := mf.Sections[y.Section].Name
+			switch {
+			case strings.HasPrefix(n, ".bss"):
+				c = 'B'
+			case strings.HasPrefix(n, ".rdata"):
+				c = 'R'
+			case strings.HasPrefix(n, ".data"):
+				c = 'D'
+			}
+		}
+		syms = append(syms, sized(sym{int(c), y.Name, y.Addr, 0, y.Size, 0}))
+	}
+	return syms, nil
+}
+
+func plan9syms(name string) ([]sym, error) {
+	f, err := plan9obj.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ys, err := f.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	var syms []sym
+	for _, y := range ys {
+		switch y.Type {
+		case 'T', 't', 'L', 'l', 'D', 'd', 'B', 'b':
+			syms = append(syms, sym{int(y.Type), y.Name, y.Value, 0, 0, 0})
+		}
+	}
+	return syms, nil
+}
+
+// module is a symbol file and where it was loaded, pref is the address
+// the symbols assume and bias is what gets added to them at runtime
+type module struct {
+	name   string
+	syms   []sym
+	maxend []uint64
+	pref   uint64
+	bias   uint64
+	fixed  bool
+	loads  []segment
+}
+
+// segment is a page aligned PT_LOAD, size covers its file backed pages
+type segment struct {
+	vaddr uint64
+	off   uint64
+	size  uint64
+}
+
+func loadmodule(arg string) (*module, error) {
+	m := &module{name: arg}
+	if i := strings.LastIndexByte(arg, '@'); i > 0 {
+		v := strings.TrimPrefix(strings.TrimPrefix(arg[i+1:], "0x"), "0X")
+		base, err := strconv.ParseUint(v, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%v: invalid base: %v", arg, err)
+		}
+		m.name = arg[:i]
+		m.bias = base
+		m.fixed = true
+	}
+
+	syms, err := getsym(m.name)
+	if err != nil {
+		return nil, err
+	}
+	m.syms = syms
+	m.layout()
+	if m.fixed {
+		m.bias -= m.pref
+	}
+
+	// maxend[i] is the furthest any of the first i+1 symbols reach,
+	// so a lookup can stop walking back once it is below the address
+	m.maxend = make([]uint64, len(syms))
+	for i := range syms {
+		m.maxend[i] = syms[i].end
+		if i > 0 && m.maxend[i-1] > m.maxend[i] {
+			m.maxend[i] = m.maxend[i-1]
+		}
+	}
+	return m, nil
+}
+
+// layout finds the preferred load address and the elf segments used to
+// recognize the module in a memory map
+func (m *module) layout() {
+	if f, err := elf.Open(m.name); err == nil {
+		defer f.Close()
+		first := true
+		for _, p := range f.Progs {
+			if p.Type != elf.PT_LOAD {
+				continue
+			}
+			if first {
+				m.pref = p.Vaddr &^ 0xfff
+				first = false
+			}
+			off := p.Off &^ 0xfff
+			m.loads = append(m.loads, segment{p.Vaddr &^ 0xfff, off, (p.Off+p.Filesz+0xfff)&^0xfff - off})
+		}
+		return
+	}
+
+	if f, err := pe.Open(m.name); err == nil {
+		defer f.Close()
+		switch h := f.OptionalHeader.(type) {
+		case *pe.OptionalHeader32:
+			m.pref = uint64(h.ImageBase)
+		case *pe.OptionalHeader64:
+			m.pref = h.ImageBase
+		}
+		return
+	}
+
+	if strings.EqualFold(filepath.Ext(m.name), ".map") {
+		f, err := os.Open(m.name)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			line := strings.TrimSpace(s.Text())
+			if strings.HasPrefix(line, "Preferred load address is") {
+				fmt.Sscanf(line, "Preferred load address is %x", &m.pref)
+				return
+			}
+		}
+	}
+}
+
+func (m *module) lookup(addr uint64) *sym {
+	// the bias wraps around for modules loaded below their preferred base
+	addr -= m.bias
+
+	p := m.syms
+	i := sort.Search(len(p), func(i int) bool {
+		return p[i].start > addr
+	}) - 1
+	for ; i >= 0 && m.maxend[i] >= addr; i-- {
+		if p[i].start <= addr && addr <= p[i].end {
+			return &p[i]
+		}
+	}
+	return nil
+}
+
+type mapping struct {
+	start, end uint64
+	off        uint64
+	path       string
 }
 
-func dump(r io.Reader, syms []sym) {
+// readmaps parses /proc/<pid>/maps lines (start-end perm off dev inode path)
+// and dump-memmap lines (start-end size perm off)
+func readmaps(name string) ([]mapping, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var maps []mapping
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fl := strings.Fields(s.Text())
+		if len(fl) < 4 {
+			continue
+		}
+		var m mapping
+		if n, _ := fmt.Sscanf(fl[0], "%x-%x", &m.start, &m.end); n != 2 {
+			continue
+		}
+		off := fl[3]
+		if len(fl[1]) == 4 && strings.Trim(fl[1], "rwxsp-") == "" {
+			off = fl[2]
+			if len(fl) >= 6 {
+				m.path = strings.Join(fl[5:], " ")
+			}
+		}
+		if _, err := fmt.Sscanf(off, "%x", &m.off); err != nil {
+			continue
+		}
+		maps = append(maps, m)
+	}
+	return maps, s.Err()
+}
+
+// rebase sets the load bias of every module from the memory map, modules
+// are matched by path if the map has them and by their segment layout if not
+func rebase(name string, mods []*module) error {
+	maps, err := readmaps(name)
+	if err != nil {
+		return err
+	}
+
+	// path matches go first so layout matching only sees the leftovers
+	claimed := make(map[uint64]bool)
+	var rest []*module
+	for _, m := range mods {
+		if m.fixed {
+			continue
+		}
+		found := false
+		abs, _ := filepath.Abs(m.name)
+		for _, p := range maps {
+			if p.path == "" || p.off != 0 || (p.path != abs && filepath.Base(p.path) != filepath.Base(m.name)) {
+				continue
+			}
+			m.bias = p.start - m.pref
+			claimed[p.start] = true
+			found = true
+			break
+		}
+		if !found {
+			rest = append(rest, m)
+		}
+	}
+
+	for _, m := range rest {
+		if len(m.loads) == 0 || !matchlayout(m, maps, claimed) {
+			return fmt.Errorf("%v: no unique mapping found in %v, give its base as %v@addr", m.name, name, m.name)
+		}
+	}
+	return nil
+}
+
+// matchlayout looks for an unclaimed mapping at file offset 0 where every
+// file backed page of the elf's loadable segments is mapped at the same
+// distance with the right offset, more than one candidate is no match
+// since libraries built with the same linker layout are common
+func matchlayout(m *module, maps []mapping, claimed map[uint64]bool) bool {
+	var biases []uint64
+	for _, p := range maps {
+		if p.off != 0 || claimed[p.start] {
+			continue
+		}
+		bias := p.start - m.loads[0].vaddr
+		if covers(m, maps, bias) {
+			biases = append(biases, bias)
+		}
+	}
+	if len(biases) != 1 {
+		return false
+	}
+	m.bias = biases[0]
+	claimed[m.bias+m.loads[0].vaddr] = true
+	return true
+}
+
+// covers reports if the mappings hold all of the module's segments at bias,
+// each segment is mapped on its own so it has to start a mapping
+func covers(m *module, maps []mapping, bias uint64) bool {
+	for _, l := range m.loads {
+		for a, end := bias+l.vaddr, bias+l.vaddr+l.size; a < end; {
+			next := a
+			for _, q := range maps {
+				if a == bias+l.vaddr && q.start != a {
+					continue
+				}
+				if q.start <= a && a < q.end && q.off+(a-q.start) == l.off+(a-bias-l.vaddr) {
+					next = q.end
+					break
+				}
+			}
+			if next == a {
+				return false
+			}
+			a = next
+		}
+	}
+	return true
+}
+
+func dump(r io.Reader, mods []*module) {
+	bout := bufio.NewWriter(os.Stdout)
+	defer bout.Flush()
+
 	s := bufio.NewScanner(r)
 	o := binary.ByteOrder(binary.LittleEndian)
 	if *end != "little" {
@@ -135,12 +691,18 @@ loop:
 			break
 		}
 
-		for _, s := range syms {
-			if s.start <= addr && addr <= s.end {
-				fmt.Printf("%#016x: %-42s %#016x-%#016x %c\n", addr, s.name, s.start, s.end, s.typ)
-				continue loop
+		for _, m := range mods {
+			y := m.lookup(addr)
+			if y == nil {
+				continue
+			}
+			name := y.name
+			if len(mods) > 1 {
+				name = filepath.Base(m.name) + "!" + name
 			}
+			fmt.Fprintf(bout, "%#016x: %-42s %#016x-%#016x %c\n", addr, name, y.start+m.bias, y.end+m.bias, y.typ)
+			continue loop
 		}
-		fmt.Printf("%#016x: %-36s\n", addr, "no match")
+		fmt.Fprintf(bout, "%#016x: %-36s\n", addr, "no match")
 	}
 }
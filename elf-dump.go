@@ -1,12 +1,26 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"hash/crc32"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/qeedquan/go-binutils/iberty/demangle"
 	"github.com/qeedquan/go-media/debug/elfutil"
 )
 
@@ -16,6 +30,31 @@ var (
 	Iflag = flag.Bool("i", false, "dump imported libraries and symbols")
 	Sflag = flag.Bool("s", false, "dump symbol table")
 
+	Cflag = flag.Bool("C", false, "demangle c++ symbol names")
+	Oflag = flag.String("o", "", "sort symbols by [addr | name | size]")
+	Tflag = flag.String("t", "", "only show symbols of the comma separated types [func,object,...]")
+	Bflag = flag.String("b", "", "only show symbols of the comma separated bindings [global,local,weak]")
+	Jflag = flag.String("j", "", "only show symbols in the named section")
+	Lflag = flag.Int("l", 0, "report the n largest symbols")
+
+	Yflag = flag.Bool("y", false, "dump dynamic section")
+	Rflag = flag.Bool("r", false, "dump relocations")
+	Vflag = flag.Bool("V", false, "dump symbol version tables")
+	Nflag = flag.Bool("n", false, "dump notes, core files also get their process state decoded")
+
+	Kflag    = flag.Bool("checksec", false, "report hardening features, arguments can be files or directories")
+	JSONflag = flag.Bool("json", false, "output checksec and size-diff reports in json format")
+
+	Wflag     = flag.Bool("w", false, "dump dwarf compile units, split units (.dwo) are only followed for dwarf 5")
+	WLflag    = flag.Bool("wl", false, "dump dwarf line tables")
+	A2Lflag   = flag.Bool("addr2line", false, "map the hex addresses following the file (or read from stdin) to function, file and line, split dwarf needs dwarf 5")
+	Debugflag = flag.String("debugdir", "/usr/lib/debug", "global directory for separate debug files")
+
+	Replaceflag = flag.String("replace", "", "replace section contents, comma separated name=file list")
+	Addflag     = flag.String("add", "", "add non-allocated sections, comma separated name=file list")
+	Removeflag  = flag.String("remove", "", "remove non-allocated sections with their relocations and section symbols, comma separated list")
+	Outflag     = flag.String("O", "", "output file for the patched elf")
+
 	status = 0
 )
 
@@ -24,6 +63,20 @@ func main() {
 	log.SetPrefix("elf-dump: ")
 	flag.Usage = usage
 	flag.Parse()
+	if flag.NArg() == 3 && flag.Arg(0) == "size-diff" {
+		sizediff(flag.Arg(1), flag.Arg(2))
+		os.Exit(status)
+	}
+	if *Kflag && flag.NArg() > 0 {
+		checksec(flag.Args())
+		os.Exit(status)
+	}
+	if *A2Lflag && flag.NArg() > 0 {
+		f, err := elfutil.Open(flag.Arg(0))
+		ck(err)
+		addr2line(f, flag.Arg(0), flag.Args()[1:])
+		os.Exit(status)
+	}
 	if flag.NArg() != 1 {
 		usage()
 	}
@@ -33,6 +86,12 @@ func main() {
 	ck(err)
 
 	switch {
+	case *Replaceflag != "" || *Addflag != "" || *Removeflag != "":
+		if *Outflag == "" {
+			log.Fatal("patching sections needs an output file (-O)")
+		}
+		ck(patchelf(f, name, *Outflag))
+
 	case *Dflag:
 		fmt.Println("Dynamic symbols:")
 		dyn, err := f.DynamicSymbols()
@@ -68,11 +127,25 @@ func main() {
 		if err != nil {
 			warnf("Failed to get symbols: %v", err)
 		}
+		dumpsyms(f, sym)
 
-		fmt.Println("Symbols: ")
-		for _, s := range sym {
-			fmt.Printf("%s %s %s\n", s.Name, s.Version, s.Library)
-		}
+	case *Yflag:
+		dumpdynamic(f)
+
+	case *Rflag:
+		dumprelocs(f)
+
+	case *Vflag:
+		dumpversions(f)
+
+	case *Nflag:
+		dumpnotes(f)
+
+	case *Wflag:
+		dumpunits(f, name)
+
+	case *WLflag:
+		dumplines(f, name)
 
 	case *Aflag:
 		base := filepath.Base(name)
@@ -80,7 +153,7 @@ func main() {
 		dumpallsects(f, dir)
 
 	default:
-		usage()
+		dumpheaders(f, name)
 	}
 	os.Exit(status)
 }
@@ -98,6 +171,7 @@ func ck(err error) {
 
 func usage() {
 	fmt.Fprintln(os.Stderr, "usage: [options] file")
+	fmt.Fprintln(os.Stderr, "       [options] size-diff old new")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -129,3 +203,2649 @@ func dumpallsects(f *elfutil.File, outdir string) {
 		}
 	}
 }
+
+var (
+	progFlags = []struct {
+		bit elf.ProgFlag
+		str string
+	}{
+		{elf.PF_R, "R"},
+		{elf.PF_W, "W"},
+		{elf.PF_X, "X"},
+	}
+
+	sectFlags = []struct {
+		bit elf.SectionFlag
+		str string
+	}{
+		{elf.SHF_WRITE, "W"},
+		{elf.SHF_ALLOC, "A"},
+		{elf.SHF_EXECINSTR, "X"},
+		{elf.SHF_MERGE, "M"},
+		{elf.SHF_STRINGS, "S"},
+		{elf.SHF_INFO_LINK, "I"},
+		{elf.SHF_LINK_ORDER, "L"},
+		{elf.SHF_OS_NONCONFORMING, "O"},
+		{elf.SHF_GROUP, "G"},
+		{elf.SHF_TLS, "T"},
+		{elf.SHF_COMPRESSED, "C"},
+		{0x80000000, "E"},
+	}
+)
+
+// elfHeader holds the header fields that debug/elf does not keep
+type elfHeader struct {
+	Flags     uint32
+	Phoff     uint64
+	Shoff     uint64
+	Ehsize    uint16
+	Phentsize uint16
+	Phnum     uint16
+	Shentsize uint16
+	Shnum     uint16
+	Shstrndx  uint16
+}
+
+func readheader(f *elfutil.File, name string) (*elfHeader, error) {
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	h := &elfHeader{}
+	switch f.Class {
+	case elf.ELFCLASS32:
+		var hdr elf.Header32
+		if err := binary.Read(fd, f.ByteOrder, &hdr); err != nil {
+			return nil, err
+		}
+		*h = elfHeader{hdr.Flags, uint64(hdr.Phoff), uint64(hdr.Shoff), hdr.Ehsize, hdr.Phentsize, hdr.Phnum, hdr.Shentsize, hdr.Shnum, hdr.Shstrndx}
+	case elf.ELFCLASS64:
+		var hdr elf.Header64
+		if err := binary.Read(fd, f.ByteOrder, &hdr); err != nil {
+			return nil, err
+		}
+		*h = elfHeader{hdr.Flags, hdr.Phoff, hdr.Shoff, hdr.Ehsize, hdr.Phentsize, hdr.Phnum, hdr.Shentsize, hdr.Shnum, hdr.Shstrndx}
+	default:
+		return nil, fmt.Errorf("unknown elf class %v", f.Class)
+	}
+	return h, nil
+}
+
+func progflags(fl elf.ProgFlag) string {
+	var b bytes.Buffer
+	for _, c := range progFlags {
+		if fl&c.bit != 0 {
+			b.WriteString(c.str)
+		} else {
+			b.WriteString(" ")
+		}
+	}
+	return b.String()
+}
+
+func sectflags(fl elf.SectionFlag) string {
+	var b bytes.Buffer
+	for _, c := range sectFlags {
+		if fl&c.bit != 0 {
+			b.WriteString(c.str)
+		}
+	}
+	return b.String()
+}
+
+// insegment reports whether an allocated section lies inside a segment,
+// tls bss only occupies the tls segment and not the load segment around it
+func insegment(s *elf.Section, p *elf.Prog) bool {
+	if s.Flags&elf.SHF_ALLOC == 0 {
+		return s.Type != elf.SHT_NOBITS && p.Type != elf.PT_LOAD && s.Offset >= p.Off && s.Offset+s.Size <= p.Off+p.Filesz && s.Size != 0
+	}
+	if s.Flags&elf.SHF_TLS != 0 && s.Type == elf.SHT_NOBITS && p.Type != elf.PT_TLS {
+		return false
+	}
+	if s.Size == 0 {
+		return s.Addr >= p.Vaddr && s.Addr < p.Vaddr+p.Memsz
+	}
+	return s.Addr >= p.Vaddr && s.Addr+s.Size <= p.Vaddr+p.Memsz
+}
+
+func dumpheaders(f *elfutil.File, name string) {
+	h, err := readheader(f, name)
+	ck(err)
+
+	fmt.Println("ELF Header:")
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("Class                : %v\n", f.Class)
+	fmt.Printf("Data                 : %v\n", f.Data)
+	fmt.Printf("Version              : %v\n", f.Version)
+	fmt.Printf("OS/ABI               : %v\n", f.OSABI)
+	fmt.Printf("ABI Version          : %d\n", f.ABIVersion)
+	fmt.Printf("Type                 : %v\n", f.Type)
+	fmt.Printf("Machine              : %v\n", f.Machine)
+	fmt.Printf("Entry                : %#x\n", f.Entry)
+	fmt.Printf("Program Headers      : %#x (%d bytes into file)\n", h.Phoff, h.Phoff)
+	fmt.Printf("Section Headers      : %#x (%d bytes into file)\n", h.Shoff, h.Shoff)
+	fmt.Printf("Flags                : %#x\n", h.Flags)
+	fmt.Printf("Header Size          : %d\n", h.Ehsize)
+	fmt.Printf("Program Header Size  : %d\n", h.Phentsize)
+	fmt.Printf("Program Headers      : %d\n", h.Phnum)
+	fmt.Printf("Section Header Size  : %d\n", h.Shentsize)
+	fmt.Printf("Section Headers      : %d\n", h.Shnum)
+	fmt.Printf("Section String Index : %d\n", h.Shstrndx)
+	fmt.Println()
+
+	fmt.Printf("Program Headers: (%d)\n", len(f.Progs))
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-3s %-16s %-3s %-10s %-18s %-18s %-10s %-10s %s\n", "Nr", "Type", "Flg", "Offset", "VirtAddr", "PhysAddr", "FileSiz", "MemSiz", "Align")
+	for i, p := range f.Progs {
+		fmt.Printf("%-3d %-16v %s %#08x %#016x %#016x %#08x %#08x %#x\n",
+			i, p.Type, progflags(p.Flags), p.Off, p.Vaddr, p.Paddr, p.Filesz, p.Memsz, p.Align)
+		if p.Type == elf.PT_INTERP && len(p.Data) > 0 {
+			fmt.Printf("    [Interpreter: %s]\n", strings.TrimRight(string(p.Data), "\x00"))
+		}
+	}
+	fmt.Println()
+
+	fmt.Println("Section to Segment Mapping:")
+	fmt.Println(strings.Repeat("-", 80))
+	for i, p := range f.Progs {
+		var names []string
+		for _, s := range f.Sections {
+			if s.Type != elf.SHT_NULL && insegment(s.Section, p.Prog) {
+				names = append(names, s.Name)
+			}
+		}
+		fmt.Printf("%-3d %s\n", i, strings.Join(names, " "))
+	}
+	fmt.Println()
+
+	fmt.Printf("Section Headers: (%d)\n", len(f.Sections))
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-3s %-24s %-16s %-18s %-10s %-10s %-4s %-5s %-3s %-4s %s\n", "Nr", "Name", "Type", "Address", "Offset", "Size", "ES", "Flg", "Lk", "Inf", "Al")
+	for i, s := range f.Sections {
+		fmt.Printf("%-3d %-24s %-16v %#016x %#08x %#08x %-4x %-5s %-3d %-4d %d\n",
+			i, s.Name, s.Type, s.Addr, s.Offset, s.Size, s.Entsize, sectflags(s.Flags), s.Link, s.Info, s.Addralign)
+	}
+	fmt.Println()
+	fmt.Println("Key to Flags:")
+	fmt.Println("W (write), A (alloc), X (execute), M (merge), S (strings), I (info),")
+	fmt.Println("L (link order), O (extra OS processing required), G (group), T (TLS),")
+	fmt.Println("C (compressed), E (exclude)")
+}
+
+type symbol struct {
+	elf.Symbol
+	Index int
+	Sect  string
+}
+
+func symtype(s *elf.Symbol) string {
+	return strings.TrimPrefix(elf.ST_TYPE(s.Info).String(), "STT_")
+}
+
+func symbind(s *elf.Symbol) string {
+	return strings.TrimPrefix(elf.ST_BIND(s.Info).String(), "STB_")
+}
+
+func symvis(s *elf.Symbol) string {
+	return strings.TrimPrefix(elf.ST_VISIBILITY(s.Other).String(), "STV_")
+}
+
+func symndx(s *elf.Symbol) string {
+	switch s.Section {
+	case elf.SHN_UNDEF:
+		return "UND"
+	case elf.SHN_ABS:
+		return "ABS"
+	case elf.SHN_COMMON:
+		return "COM"
+	}
+	return fmt.Sprint(int(s.Section))
+}
+
+func symname(s *symbol) string {
+	name := s.Name
+	if *Cflag {
+		if cname := demangle.Cplus(name, demangle.PARAMS|demangle.TYPES|demangle.VERBOSE); cname != "" {
+			name = cname
+		}
+	}
+	if s.Version != "" {
+		name += "@" + s.Version
+	}
+	return name
+}
+
+// matchlist reports whether str is in a comma separated list,
+// an empty list matches everything
+func matchlist(list, str string) bool {
+	if list == "" {
+		return true
+	}
+	for _, l := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(l), str) {
+			return true
+		}
+	}
+	return false
+}
+
+func filtersyms(f *elfutil.File, sym []elf.Symbol) []symbol {
+	var p []symbol
+	for i := range sym {
+		s := symbol{sym[i], i + 1, ""}
+		if n := int(s.Section); s.Section < elf.SHN_LORESERVE && n < len(f.Sections) {
+			s.Sect = f.Sections[n].Name
+		}
+		if !matchlist(*Tflag, symtype(&s.Symbol)) || !matchlist(*Bflag, symbind(&s.Symbol)) {
+			continue
+		}
+		if *Jflag != "" && s.Sect != *Jflag {
+			continue
+		}
+		p = append(p, s)
+	}
+	return p
+}
+
+func sortsyms(p []symbol, order string) {
+	switch order {
+	case "", "none":
+	case "addr":
+		sort.SliceStable(p, func(i, j int) bool {
+			return p[i].Value < p[j].Value
+		})
+	case "name":
+		sort.SliceStable(p, func(i, j int) bool {
+			return p[i].Name < p[j].Name
+		})
+	case "size":
+		sort.SliceStable(p, func(i, j int) bool {
+			return p[i].Size > p[j].Size
+		})
+	default:
+		log.Fatalf("unknown sort order %q", order)
+	}
+}
+
+func dumpsyms(f *elfutil.File, sym []elf.Symbol) {
+	p := filtersyms(f, sym)
+	if *Lflag > 0 {
+		dumplargest(p, *Lflag)
+		return
+	}
+	sortsyms(p, *Oflag)
+
+	fmt.Printf("Symbols: (%d)\n", len(p))
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%6s %-18s %8s %-8s %-8s %-9s %-5s %s\n", "Num", "Value", "Size", "Type", "Bind", "Vis", "Ndx", "Name")
+	for i := range p {
+		s := &p[i]
+		fmt.Printf("%6d %#016x %8d %-8s %-8s %-9s %-5s %s\n",
+			s.Index, s.Value, s.Size, symtype(&s.Symbol), symbind(&s.Symbol), symvis(&s.Symbol), symndx(&s.Symbol), symname(s))
+	}
+}
+
+func dumplargest(p []symbol, n int) {
+	var total uint64
+	for i := range p {
+		if p[i].Section != elf.SHN_UNDEF {
+			total += p[i].Size
+		}
+	}
+	sortsyms(p, "size")
+	if n > len(p) {
+		n = len(p)
+	}
+
+	fmt.Printf("Largest Symbols: (%d of %d, %d bytes total)\n", n, len(p), total)
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%10s %7s %7s %-8s %-20s %s\n", "Size", "Pct", "Cum", "Type", "Section", "Name")
+	var cum uint64
+	for i := 0; i < n; i++ {
+		s := &p[i]
+		cum += s.Size
+		fmt.Printf("%10d %6.2f%% %6.2f%% %-8s %-20s %s\n",
+			s.Size, percent(s.Size, total), percent(cum, total), symtype(&s.Symbol), s.Sect, symname(s))
+	}
+}
+
+func percent(x, y uint64) float64 {
+	if y == 0 {
+		return 0
+	}
+	return float64(x) * 100 / float64(y)
+}
+
+// not defined by debug/elf yet
+const (
+	SHT_RELR = elf.SectionType(19)
+
+	DT_RELRSZ  = elf.DynTag(35)
+	DT_RELR    = elf.DynTag(36)
+	DT_RELRENT = elf.DynTag(37)
+)
+
+func dyntag(t elf.DynTag) string {
+	switch t {
+	case DT_RELRSZ:
+		return "DT_RELRSZ"
+	case DT_RELR:
+		return "DT_RELR"
+	case DT_RELRENT:
+		return "DT_RELRENT"
+	}
+	return t.String()
+}
+
+func cstring(b []byte, off uint64) string {
+	if off >= uint64(len(b)) {
+		return fmt.Sprintf("<corrupt string offset %#x>", off)
+	}
+	b = b[off:]
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func wordsize(f *elfutil.File) int {
+	if f.Class == elf.ELFCLASS64 {
+		return 8
+	}
+	return 4
+}
+
+func word(f *elfutil.File, b []byte) uint64 {
+	if f.Class == elf.ELFCLASS64 {
+		return f.ByteOrder.Uint64(b)
+	}
+	return uint64(f.ByteOrder.Uint32(b))
+}
+
+// vaddrdata returns the file contents of the loaded image at addr
+func vaddrdata(f *elfutil.File, addr uint64) []byte {
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD && p.Vaddr <= addr && addr < p.Vaddr+p.Filesz && addr-p.Vaddr < uint64(len(p.Data)) {
+			return p.Data[addr-p.Vaddr:]
+		}
+	}
+	return nil
+}
+
+type dynentry struct {
+	Tag elf.DynTag
+	Val uint64
+}
+
+func readdynamic(f *elfutil.File) ([]dynentry, []byte) {
+	var data, strtab []byte
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_DYNAMIC {
+			data = s.Data
+			if int(s.Link) < len(f.Sections) {
+				strtab = f.Sections[s.Link].Data
+			}
+			break
+		}
+	}
+	if data == nil {
+		for _, p := range f.Progs {
+			if p.Type == elf.PT_DYNAMIC {
+				data = p.Data
+				break
+			}
+		}
+	}
+
+	var d []dynentry
+	n := wordsize(f)
+	for ; len(data) >= 2*n; data = data[2*n:] {
+		e := dynentry{elf.DynTag(word(f, data)), word(f, data[n:])}
+		d = append(d, e)
+		if e.Tag == elf.DT_NULL {
+			break
+		}
+	}
+
+	// no section headers, find the string table through the loaded image
+	if strtab == nil {
+		for _, e := range d {
+			if e.Tag == elf.DT_STRTAB {
+				strtab = vaddrdata(f, e.Val)
+			}
+		}
+	}
+	return d, strtab
+}
+
+func dynvalue(f *elfutil.File, d []dynentry, e dynentry, strtab []byte) string {
+	switch e.Tag {
+	case elf.DT_NEEDED:
+		return fmt.Sprintf("Shared library: [%s]", cstring(strtab, e.Val))
+	case elf.DT_SONAME:
+		return fmt.Sprintf("Library soname: [%s]", cstring(strtab, e.Val))
+	case elf.DT_RPATH:
+		return fmt.Sprintf("Library rpath: [%s]", cstring(strtab, e.Val))
+	case elf.DT_RUNPATH:
+		return fmt.Sprintf("Library runpath: [%s]", cstring(strtab, e.Val))
+	case elf.DT_FLAGS:
+		return elf.DynFlag(e.Val).String()
+	case elf.DT_FLAGS_1:
+		return elf.DynFlag1(e.Val).String()
+	case elf.DT_PLTREL:
+		return dyntag(elf.DynTag(e.Val))
+	case elf.DT_PLTRELSZ, elf.DT_RELASZ, elf.DT_RELAENT, elf.DT_RELSZ, elf.DT_RELENT, DT_RELRSZ, DT_RELRENT,
+		elf.DT_STRSZ, elf.DT_SYMENT, elf.DT_INIT_ARRAYSZ, elf.DT_FINI_ARRAYSZ, elf.DT_PREINIT_ARRAYSZ:
+		return fmt.Sprintf("%d (bytes)", e.Val)
+	case elf.DT_INIT_ARRAY, elf.DT_FINI_ARRAY, elf.DT_PREINIT_ARRAY:
+		size := map[elf.DynTag]elf.DynTag{
+			elf.DT_INIT_ARRAY:    elf.DT_INIT_ARRAYSZ,
+			elf.DT_FINI_ARRAY:    elf.DT_FINI_ARRAYSZ,
+			elf.DT_PREINIT_ARRAY: elf.DT_PREINIT_ARRAYSZ,
+		}[e.Tag]
+		var n uint64
+		for _, x := range d {
+			if x.Tag == size {
+				n = x.Val
+			}
+		}
+		var addrs []string
+		b := vaddrdata(f, e.Val)
+		w := uint64(wordsize(f))
+		for i := uint64(0); i+w <= n && i+w <= uint64(len(b)); i += w {
+			addrs = append(addrs, fmt.Sprintf("%#x", word(f, b[i:])))
+		}
+		return fmt.Sprintf("%#x [%s]", e.Val, strings.Join(addrs, " "))
+	}
+	return fmt.Sprintf("%#x", e.Val)
+}
+
+func dumpdynamic(f *elfutil.File) {
+	d, strtab := readdynamic(f)
+	fmt.Printf("Dynamic Section: (%d)\n", len(d))
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-18s %-20s %s\n", "Tag", "Type", "Value")
+	for _, e := range d {
+		fmt.Printf("%#016x %-20s %s\n", uint64(e.Tag), dyntag(e.Tag), dynvalue(f, d, e, strtab))
+	}
+}
+
+// reltype names a relocation type for the machine
+func reltype(m elf.Machine, t uint32) string {
+	var s fmt.Stringer
+	switch m {
+	case elf.EM_X86_64:
+		s = elf.R_X86_64(t)
+	case elf.EM_386:
+		s = elf.R_386(t)
+	case elf.EM_AARCH64:
+		s = elf.R_AARCH64(t)
+	case elf.EM_ARM:
+		s = elf.R_ARM(t)
+	case elf.EM_RISCV:
+		s = elf.R_RISCV(t)
+	case elf.EM_PPC:
+		s = elf.R_PPC(t)
+	case elf.EM_PPC64:
+		s = elf.R_PPC64(t)
+	case elf.EM_MIPS:
+		s = elf.R_MIPS(t)
+	case elf.EM_S390:
+		s = elf.R_390(t)
+	case elf.EM_SPARC, elf.EM_SPARCV9:
+		s = elf.R_SPARC(t)
+	case elf.EM_LOONGARCH:
+		s = elf.R_LARCH(t)
+	default:
+		return fmt.Sprintf("%#x", t)
+	}
+	return s.String()
+}
+
+// linkedsyms returns the symbol table a relocation or version section refers to,
+// indexed by the raw symbol index
+func linkedsyms(f *elfutil.File, link uint32) []elf.Symbol {
+	if int(link) >= len(f.Sections) || link == 0 {
+		return nil
+	}
+	var (
+		sym []elf.Symbol
+		err error
+	)
+	switch f.Sections[link].Type {
+	case elf.SHT_DYNSYM:
+		sym, err = f.DynamicSymbols()
+	case elf.SHT_SYMTAB:
+		sym, err = f.Symbols()
+	default:
+		return nil
+	}
+	if err != nil {
+		warnf("Failed to get symbols for relocations: %v", err)
+	}
+	return append([]elf.Symbol{{}}, sym...)
+}
+
+func dumprelocs(f *elfutil.File) {
+	found := false
+	for _, s := range f.Sections {
+		switch s.Type {
+		case elf.SHT_REL, elf.SHT_RELA:
+			dumprel(f, s)
+		case SHT_RELR:
+			dumprelr(f, s)
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		fmt.Println("No relocations")
+	}
+}
+
+func dumprel(f *elfutil.File, s *elfutil.Section) {
+	sym := linkedsyms(f, s.Link)
+	rela := s.Type == elf.SHT_RELA
+	w := wordsize(f)
+	n := 2 * w
+	if rela {
+		n += w
+	}
+
+	fmt.Printf("Relocation Section %s at offset %#x: (%d)\n", s.Name, s.Offset, len(s.Data)/n)
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-18s %-18s %-24s %-18s %s\n", "Offset", "Info", "Type", "Sym Value", "Sym Name + Addend")
+	for b := s.Data; len(b) >= n; b = b[n:] {
+		off := word(f, b)
+		info := word(f, b[w:])
+
+		var (
+			symidx uint64
+			typ    uint32
+		)
+		if f.Class == elf.ELFCLASS64 {
+			symidx, typ = info>>32, uint32(info)
+		} else {
+			symidx, typ = info>>8, uint32(info&0xff)
+		}
+
+		var addend int64
+		if rela {
+			addend = int64(word(f, b[2*w:]))
+			if w == 4 {
+				addend = int64(int32(addend))
+			}
+		}
+
+		var value, name string
+		if symidx != 0 && symidx < uint64(len(sym)) {
+			y := &sym[symidx]
+			value = fmt.Sprintf("%#016x", y.Value)
+			name = y.Name
+			if name == "" && elf.ST_TYPE(y.Info) == elf.STT_SECTION && int(y.Section) < len(f.Sections) {
+				name = f.Sections[y.Section].Name
+			}
+			if y.Version != "" {
+				name += "@" + y.Version
+			}
+		}
+		if rela {
+			switch {
+			case name != "":
+				name = fmt.Sprintf("%s %+#x", name, addend)
+			default:
+				name = fmt.Sprintf("%#x", addend)
+			}
+		}
+		fmt.Printf("%#016x %#016x %-24s %-18s %s\n", off, info, reltype(f.Machine, typ), value, name)
+	}
+	fmt.Println()
+}
+
+// dumprelr expands the packed relative relocations, an even entry is
+// an address and an odd entry is a bitmap of the words that follow it
+func dumprelr(f *elfutil.File, s *elfutil.Section) {
+	w := wordsize(f)
+	var addrs []uint64
+	var base uint64
+	for b := s.Data; len(b) >= w; b = b[w:] {
+		e := word(f, b)
+		if e&1 == 0 {
+			addrs = append(addrs, e)
+			base = e + uint64(w)
+			continue
+		}
+		for i, bits := 0, e>>1; bits != 0; i, bits = i+1, bits>>1 {
+			if bits&1 != 0 {
+				addrs = append(addrs, base+uint64(i*w))
+			}
+		}
+		base += uint64((8*w - 1) * w)
+	}
+
+	fmt.Printf("Relocation Section %s at offset %#x: (%d entries, %d relocations)\n", s.Name, s.Offset, len(s.Data)/w, len(addrs))
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-18s %s\n", "Offset", "Type")
+	typ := "RELATIVE"
+	switch f.Machine {
+	case elf.EM_X86_64:
+		typ = elf.R_X86_64_RELATIVE.String()
+	case elf.EM_386:
+		typ = elf.R_386_RELATIVE.String()
+	case elf.EM_AARCH64:
+		typ = elf.R_AARCH64_RELATIVE.String()
+	case elf.EM_ARM:
+		typ = elf.R_ARM_RELATIVE.String()
+	case elf.EM_RISCV:
+		typ = elf.R_RISCV_RELATIVE.String()
+	}
+	for _, a := range addrs {
+		fmt.Printf("%#016x %s\n", a, typ)
+	}
+	fmt.Println()
+}
+
+// readversions maps version indexes to names from the definition and need tables
+func readversions(f *elfutil.File) map[uint16]string {
+	m := make(map[uint16]string)
+	for _, s := range f.Sections {
+		if int(s.Link) >= len(f.Sections) {
+			continue
+		}
+		strtab := f.Sections[s.Link].Data
+		switch s.Type {
+		case elf.SHT_GNU_VERDEF:
+			eachverdef(f, s.Data, func(ndx, flags uint16, names []string) {
+				if len(names) > 0 {
+					m[ndx] = names[0]
+				}
+			}, strtab)
+		case elf.SHT_GNU_VERNEED:
+			eachverneed(f, s.Data, func(file string, ndx, flags uint16, name string) {
+				m[ndx] = name
+			}, strtab)
+		}
+	}
+	return m
+}
+
+func eachverdef(f *elfutil.File, b []byte, fn func(ndx, flags uint16, names []string), strtab []byte) {
+	bo := f.ByteOrder
+	for off := uint64(0); off+20 <= uint64(len(b)); {
+		d := b[off:]
+		flags := bo.Uint16(d[2:])
+		ndx := bo.Uint16(d[4:])
+		cnt := bo.Uint16(d[6:])
+		aux := uint64(bo.Uint32(d[12:]))
+		next := uint64(bo.Uint32(d[16:]))
+
+		var names []string
+		for i, a := uint16(0), off+aux; i < cnt && a+8 <= uint64(len(b)); i++ {
+			names = append(names, cstring(strtab, uint64(bo.Uint32(b[a:]))))
+			n := uint64(bo.Uint32(b[a+4:]))
+			if n == 0 {
+				break
+			}
+			a += n
+		}
+		fn(ndx, flags, names)
+		if next == 0 {
+			break
+		}
+		off += next
+	}
+}
+
+func eachverneed(f *elfutil.File, b []byte, fn func(file string, ndx, flags uint16, name string), strtab []byte) {
+	bo := f.ByteOrder
+	for off := uint64(0); off+16 <= uint64(len(b)); {
+		d := b[off:]
+		cnt := bo.Uint16(d[2:])
+		file := cstring(strtab, uint64(bo.Uint32(d[4:])))
+		aux := uint64(bo.Uint32(d[8:]))
+		next := uint64(bo.Uint32(d[12:]))
+
+		for i, a := uint16(0), off+aux; i < cnt && a+16 <= uint64(len(b)); i++ {
+			x := b[a:]
+			flags := bo.Uint16(x[4:])
+			other := bo.Uint16(x[6:])
+			fn(file, other, flags, cstring(strtab, uint64(bo.Uint32(x[8:]))))
+			n := uint64(bo.Uint32(x[12:]))
+			if n == 0 {
+				break
+			}
+			a += n
+		}
+		if next == 0 {
+			break
+		}
+		off += next
+	}
+}
+
+func verflags(fl uint16) string {
+	var s []string
+	if fl&1 != 0 {
+		s = append(s, "BASE")
+	}
+	if fl&2 != 0 {
+		s = append(s, "WEAK")
+	}
+	if fl&^3 != 0 {
+		s = append(s, fmt.Sprintf("%#x", fl&^3))
+	}
+	if len(s) == 0 {
+		return "none"
+	}
+	return strings.Join(s, " | ")
+}
+
+func dumpversions(f *elfutil.File) {
+	names := readversions(f)
+	found := false
+	for _, s := range f.Sections {
+		if int(s.Link) >= len(f.Sections) {
+			continue
+		}
+		strtab := f.Sections[s.Link].Data
+		switch s.Type {
+		case elf.SHT_GNU_VERSYM:
+			sym := linkedsyms(f, s.Link)
+			fmt.Printf("Version Symbols Section %s: (%d)\n", s.Name, len(s.Data)/2)
+			fmt.Println(strings.Repeat("-", 80))
+			for i := 0; 2*i+2 <= len(s.Data); i++ {
+				v := f.ByteOrder.Uint16(s.Data[2*i:])
+				ndx := v & 0x7fff
+				var desc string
+				switch ndx {
+				case 0:
+					desc = "*local*"
+				case 1:
+					desc = "*global*"
+				default:
+					desc = names[ndx]
+				}
+				if v&0x8000 != 0 {
+					desc += " (hidden)"
+				}
+				var name string
+				if i < len(sym) {
+					name = sym[i].Name
+				}
+				fmt.Printf("%6d 0x%04x %-24s %s\n", i, v, desc, name)
+			}
+
+		case elf.SHT_GNU_VERDEF:
+			fmt.Printf("Version Definition Section %s:\n", s.Name)
+			fmt.Println(strings.Repeat("-", 80))
+			eachverdef(f, s.Data, func(ndx, flags uint16, names []string) {
+				var name string
+				if len(names) > 0 {
+					name = names[0]
+				}
+				fmt.Printf("Index: %d Flags: %s Name: %s\n", ndx, verflags(flags), name)
+				for i := 1; i < len(names); i++ {
+					fmt.Printf("    Parent %d: %s\n", i, names[i])
+				}
+			}, strtab)
+
+		case elf.SHT_GNU_VERNEED:
+			fmt.Printf("Version Needs Section %s:\n", s.Name)
+			fmt.Println(strings.Repeat("-", 80))
+			last := ""
+			eachverneed(f, s.Data, func(file string, ndx, flags uint16, name string) {
+				if file != last {
+					fmt.Printf("File: %s\n", file)
+					last = file
+				}
+				fmt.Printf("    Index: %d Flags: %s Name: %s\n", ndx, verflags(flags), name)
+			}, strtab)
+
+		default:
+			continue
+		}
+		found = true
+		fmt.Println()
+	}
+	if !found {
+		fmt.Println("No version information")
+	}
+}
+
+type note struct {
+	Name string
+	Type uint32
+	Desc []byte
+}
+
+// readnotes parses a note table, desc fields are aligned to 4 bytes
+// except for notes in 8 byte aligned containers like the gnu properties
+func readnotes(f *elfutil.File, b []byte, align uint64) []note {
+	if align != 8 {
+		align = 4
+	}
+	pad := func(n uint64) uint64 { return (n + align - 1) &^ (align - 1) }
+
+	var n []note
+	bo := f.ByteOrder
+	for off := uint64(0); off+12 <= uint64(len(b)); {
+		namesz := uint64(bo.Uint32(b[off:]))
+		descsz := uint64(bo.Uint32(b[off+4:]))
+		typ := bo.Uint32(b[off+8:])
+		off += 12
+
+		if off+namesz > uint64(len(b)) {
+			break
+		}
+		name := strings.TrimRight(string(b[off:off+namesz]), "\x00")
+		off = pad(off + namesz)
+
+		if off+descsz > uint64(len(b)) {
+			break
+		}
+		n = append(n, note{name, typ, b[off : off+descsz]})
+		off = pad(off + descsz)
+	}
+	return n
+}
+
+var noteTypes = map[string]map[uint32]string{
+	"GNU": {
+		1: "NT_GNU_ABI_TAG",
+		2: "NT_GNU_HWCAP",
+		3: "NT_GNU_BUILD_ID",
+		4: "NT_GNU_GOLD_VERSION",
+		5: "NT_GNU_PROPERTY_TYPE_0",
+	},
+	"Go": {
+		4: "NT_GO_BUILDID",
+	},
+	"CORE": {
+		1:          "NT_PRSTATUS",
+		2:          "NT_FPREGSET",
+		3:          "NT_PRPSINFO",
+		4:          "NT_TASKSTRUCT",
+		6:          "NT_AUXV",
+		0x53494749: "NT_SIGINFO",
+		0x46494c45: "NT_FILE",
+	},
+	"LINUX": {
+		0x200: "NT_386_TLS",
+		0x201: "NT_386_IOPERM",
+		0x202: "NT_X86_XSTATE",
+		0x204: "NT_X86_SHSTK",
+		0x400: "NT_ARM_VFP",
+		0x401: "NT_ARM_TLS",
+		0x402: "NT_ARM_HW_BREAK",
+		0x403: "NT_ARM_HW_WATCH",
+		0x404: "NT_ARM_SYSTEM_CALL",
+		0x405: "NT_ARM_SVE",
+		0x406: "NT_ARM_PAC_MASK",
+		0x409: "NT_ARM_TAGGED_ADDR_CTRL",
+	},
+}
+
+func notetype(n *note) string {
+	if s, ok := noteTypes[n.Name][n.Type]; ok {
+		return s
+	}
+	return fmt.Sprintf("%#x", n.Type)
+}
+
+func dumpnotes(f *elfutil.File) {
+	found := false
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOTE {
+			fmt.Printf("Notes Section %s at offset %#x:\n", s.Name, s.Offset)
+			dumpnotetab(f, readnotes(f, s.Data, s.Addralign))
+			found = true
+		}
+	}
+	// core files and stripped headers only have the segments
+	if !found {
+		for i, p := range f.Progs {
+			if p.Type == elf.PT_NOTE {
+				fmt.Printf("Notes Segment %d at offset %#x:\n", i, p.Off)
+				dumpnotetab(f, readnotes(f, p.Data, p.Align))
+				found = true
+			}
+		}
+	}
+	if !found {
+		fmt.Println("No notes")
+	}
+}
+
+func dumpnotetab(f *elfutil.File, n []note) {
+	fmt.Println(strings.Repeat("-", 80))
+	for i := range n {
+		fmt.Printf("%-8s %#08x %-24s %d bytes\n", n[i].Name, n[i].Type, notetype(&n[i]), len(n[i].Desc))
+		switch n[i].Name {
+		case "GNU":
+			dumpgnunote(f, &n[i])
+		case "Go":
+			if n[i].Type == 4 {
+				fmt.Printf("    Build ID: %s\n", n[i].Desc)
+			}
+		case "CORE", "LINUX":
+			dumpcorenote(f, &n[i])
+		}
+	}
+	fmt.Println()
+}
+
+func dumpgnunote(f *elfutil.File, n *note) {
+	bo := f.ByteOrder
+	d := n.Desc
+	switch n.Type {
+	case 1:
+		if len(d) < 16 {
+			break
+		}
+		osname := map[uint32]string{0: "Linux", 1: "Hurd", 2: "Solaris", 3: "FreeBSD", 4: "NetBSD", 5: "Syllable"}[bo.Uint32(d)]
+		fmt.Printf("    OS: %s, ABI: %d.%d.%d\n", osname, bo.Uint32(d[4:]), bo.Uint32(d[8:]), bo.Uint32(d[12:]))
+	case 3:
+		fmt.Printf("    Build ID: %x\n", d)
+	case 4:
+		fmt.Printf("    Version: %s\n", strings.TrimRight(string(d), "\x00"))
+	case 5:
+		dumpproperties(f, d)
+	}
+}
+
+const (
+	GNU_PROPERTY_STACK_SIZE           = 1
+	GNU_PROPERTY_NO_COPY_ON_PROTECTED = 2
+	GNU_PROPERTY_AARCH64_FEATURE_1    = 0xc0000000
+	GNU_PROPERTY_X86_FEATURE_1_AND    = 0xc0000002
+	GNU_PROPERTY_X86_FEATURE_2_NEEDED = 0xc0008001
+	GNU_PROPERTY_X86_ISA_1_NEEDED     = 0xc0008002
+	GNU_PROPERTY_X86_FEATURE_2_USED   = 0xc0010001
+	GNU_PROPERTY_X86_ISA_1_USED       = 0xc0010002
+)
+
+var (
+	x86Feature1 = []struct {
+		bit uint32
+		str string
+	}{
+		{1 << 0, "IBT"},
+		{1 << 1, "SHSTK"},
+		{1 << 2, "LAM_U48"},
+		{1 << 3, "LAM_U57"},
+	}
+
+	x86Feature2 = []struct {
+		bit uint32
+		str string
+	}{
+		{1 << 0, "x86"},
+		{1 << 1, "x87"},
+		{1 << 2, "MMX"},
+		{1 << 3, "XMM"},
+		{1 << 4, "YMM"},
+		{1 << 5, "ZMM"},
+		{1 << 6, "FXSR"},
+		{1 << 7, "XSAVE"},
+		{1 << 8, "XSAVEOPT"},
+		{1 << 9, "XSAVEC"},
+		{1 << 10, "TMM"},
+		{1 << 11, "MASK"},
+	}
+
+	x86ISA1 = []struct {
+		bit uint32
+		str string
+	}{
+		{1 << 0, "x86-64-baseline"},
+		{1 << 1, "x86-64-v2"},
+		{1 << 2, "x86-64-v3"},
+		{1 << 3, "x86-64-v4"},
+	}
+
+	aarch64Feature1 = []struct {
+		bit uint32
+		str string
+	}{
+		{1 << 0, "BTI"},
+		{1 << 1, "PAC"},
+		{1 << 2, "GCS"},
+	}
+)
+
+func bitnames(v uint32, tab []struct {
+	bit uint32
+	str string
+}) string {
+	var s []string
+	for _, t := range tab {
+		if v&t.bit != 0 {
+			s = append(s, t.str)
+			v &^= t.bit
+		}
+	}
+	if v != 0 {
+		s = append(s, fmt.Sprintf("%#x", v))
+	}
+	if len(s) == 0 {
+		return "<none>"
+	}
+	return strings.Join(s, ", ")
+}
+
+func dumpproperties(f *elfutil.File, d []byte) {
+	bo := f.ByteOrder
+	align := uint64(wordsize(f))
+	for off := uint64(0); off+8 <= uint64(len(d)); {
+		typ := bo.Uint32(d[off:])
+		size := uint64(bo.Uint32(d[off+4:]))
+		off += 8
+		if off+size > uint64(len(d)) {
+			fmt.Printf("    <corrupt property %#x>\n", typ)
+			return
+		}
+		p := d[off : off+size]
+		off = (off + size + align - 1) &^ (align - 1)
+
+		var v uint32
+		if len(p) >= 4 {
+			v = bo.Uint32(p)
+		}
+		switch {
+		case typ == GNU_PROPERTY_STACK_SIZE:
+			if len(p) >= wordsize(f) {
+				fmt.Printf("    Stack size: %#x\n", word(f, p))
+			}
+		case typ == GNU_PROPERTY_NO_COPY_ON_PROTECTED:
+			fmt.Printf("    No copy on protected\n")
+		case f.Machine == elf.EM_AARCH64 && typ == GNU_PROPERTY_AARCH64_FEATURE_1:
+			fmt.Printf("    AArch64 feature: %s\n", bitnames(v, aarch64Feature1))
+		case typ == GNU_PROPERTY_X86_FEATURE_1_AND:
+			fmt.Printf("    x86 feature: %s\n", bitnames(v, x86Feature1))
+		case typ == GNU_PROPERTY_X86_FEATURE_2_NEEDED:
+			fmt.Printf("    x86 feature needed: %s\n", bitnames(v, x86Feature2))
+		case typ == GNU_PROPERTY_X86_FEATURE_2_USED:
+			fmt.Printf("    x86 feature used: %s\n", bitnames(v, x86Feature2))
+		case typ == GNU_PROPERTY_X86_ISA_1_NEEDED:
+			fmt.Printf("    x86 ISA needed: %s\n", bitnames(v, x86ISA1))
+		case typ == GNU_PROPERTY_X86_ISA_1_USED:
+			fmt.Printf("    x86 ISA used: %s\n", bitnames(v, x86ISA1))
+		default:
+			fmt.Printf("    Property %#x: %x\n", typ, p)
+		}
+	}
+}
+
+var (
+	x86_64Regs = []string{
+		"r15", "r14", "r13", "r12", "rbp", "rbx", "r11", "r10",
+		"r9", "r8", "rax", "rcx", "rdx", "rsi", "rdi", "orig_rax",
+		"rip", "cs", "eflags", "rsp", "ss", "fs_base", "gs_base",
+		"ds", "es", "fs", "gs",
+	}
+
+	aarch64Regs = []string{
+		"x0", "x1", "x2", "x3", "x4", "x5", "x6", "x7",
+		"x8", "x9", "x10", "x11", "x12", "x13", "x14", "x15",
+		"x16", "x17", "x18", "x19", "x20", "x21", "x22", "x23",
+		"x24", "x25", "x26", "x27", "x28", "x29", "x30", "sp",
+		"pc", "pstate",
+	}
+
+	signals = map[int32]string{
+		1: "SIGHUP", 2: "SIGINT", 3: "SIGQUIT", 4: "SIGILL", 5: "SIGTRAP",
+		6: "SIGABRT", 7: "SIGBUS", 8: "SIGFPE", 9: "SIGKILL", 10: "SIGUSR1",
+		11: "SIGSEGV", 12: "SIGUSR2", 13: "SIGPIPE", 14: "SIGALRM", 15: "SIGTERM",
+		16: "SIGSTKFLT", 17: "SIGCHLD", 18: "SIGCONT", 19: "SIGSTOP", 20: "SIGTSTP",
+		21: "SIGTTIN", 22: "SIGTTOU", 23: "SIGURG", 24: "SIGXCPU", 25: "SIGXFSZ",
+		26: "SIGVTALRM", 27: "SIGPROF", 28: "SIGWINCH", 29: "SIGIO", 30: "SIGPWR",
+		31: "SIGSYS",
+	}
+
+	auxvTypes = map[uint64]string{
+		0: "AT_NULL", 1: "AT_IGNORE", 2: "AT_EXECFD", 3: "AT_PHDR", 4: "AT_PHENT",
+		5: "AT_PHNUM", 6: "AT_PAGESZ", 7: "AT_BASE", 8: "AT_FLAGS", 9: "AT_ENTRY",
+		10: "AT_NOTELF", 11: "AT_UID", 12: "AT_EUID", 13: "AT_GID", 14: "AT_EGID",
+		15: "AT_PLATFORM", 16: "AT_HWCAP", 17: "AT_CLKTCK", 23: "AT_SECURE",
+		24: "AT_BASE_PLATFORM", 25: "AT_RANDOM", 26: "AT_HWCAP2", 27: "AT_RSEQ_FEATURE_SIZE",
+		28: "AT_RSEQ_ALIGN", 29: "AT_HWCAP3", 30: "AT_HWCAP4", 31: "AT_EXECFN",
+		32: "AT_SYSINFO", 33: "AT_SYSINFO_EHDR", 51: "AT_MINSIGSTKSZ",
+	}
+)
+
+func signame(sig int32) string {
+	if s, ok := signals[sig]; ok {
+		return s
+	}
+	return fmt.Sprint(sig)
+}
+
+// dumpcorenote decodes the process state the kernel writes into core files,
+// the layouts are the 64 bit linux ones
+func dumpcorenote(f *elfutil.File, n *note) {
+	if n.Name != "CORE" || f.Class != elf.ELFCLASS64 {
+		return
+	}
+
+	bo := f.ByteOrder
+	d := n.Desc
+	switch n.Type {
+	case 1:
+		if len(d) < 112 {
+			break
+		}
+		fmt.Printf("    Signal: %s, Code: %d, Errno: %d, Current Signal: %s\n",
+			signame(int32(bo.Uint32(d))), int32(bo.Uint32(d[4:])), int32(bo.Uint32(d[8:])), signame(int32(bo.Uint16(d[12:]))))
+		fmt.Printf("    PID: %d, PPID: %d, PGRP: %d, SID: %d\n",
+			bo.Uint32(d[32:]), bo.Uint32(d[36:]), bo.Uint32(d[40:]), bo.Uint32(d[44:]))
+		fmt.Printf("    Pending: %#x, Held: %#x\n", bo.Uint64(d[16:]), bo.Uint64(d[24:]))
+
+		var regs []string
+		switch f.Machine {
+		case elf.EM_X86_64:
+			regs = x86_64Regs
+		case elf.EM_AARCH64:
+			regs = aarch64Regs
+		default:
+			fmt.Printf("    Registers not decoded for %v\n", f.Machine)
+			return
+		}
+		r := d[112:]
+		for i, name := range regs {
+			if 8*i+8 > len(r) {
+				break
+			}
+			fmt.Printf("    %-8s %#016x", name, bo.Uint64(r[8*i:]))
+			if i%3 == 2 || i == len(regs)-1 {
+				fmt.Println()
+			}
+		}
+
+	case 3:
+		if len(d) < 136 {
+			break
+		}
+		fmt.Printf("    State: %d (%c), Zombie: %d, Nice: %d, Flags: %#x\n", d[0], d[1], d[2], int8(d[3]), bo.Uint64(d[8:]))
+		fmt.Printf("    UID: %d, GID: %d, PID: %d, PPID: %d, PGRP: %d, SID: %d\n",
+			bo.Uint32(d[16:]), bo.Uint32(d[20:]), bo.Uint32(d[24:]), bo.Uint32(d[28:]), bo.Uint32(d[32:]), bo.Uint32(d[36:]))
+		fmt.Printf("    Name: %s\n", cstring(d[40:56], 0))
+		fmt.Printf("    Args: %s\n", cstring(d[56:136], 0))
+
+	case 6:
+		for ; len(d) >= 16; d = d[16:] {
+			typ, val := bo.Uint64(d), bo.Uint64(d[8:])
+			name, ok := auxvTypes[typ]
+			if !ok {
+				name = fmt.Sprint(typ)
+			}
+			fmt.Printf("    %-20s %#x\n", name, val)
+			if typ == 0 {
+				break
+			}
+		}
+
+	case 0x53494749:
+		if len(d) < 24 {
+			break
+		}
+		sig := int32(bo.Uint32(d))
+		fmt.Printf("    Signal: %s, Errno: %d, Code: %d\n", signame(sig), int32(bo.Uint32(d[4:])), int32(bo.Uint32(d[8:])))
+		switch sig {
+		case 4, 7, 8, 11:
+			fmt.Printf("    Fault Address: %#x\n", bo.Uint64(d[16:]))
+		default:
+			fmt.Printf("    Sender PID: %d, UID: %d\n", bo.Uint32(d[16:]), bo.Uint32(d[20:]))
+		}
+
+	case 0x46494c45:
+		if len(d) < 16 {
+			break
+		}
+		count := bo.Uint64(d)
+		pagesize := bo.Uint64(d[8:])
+		if count > (uint64(len(d))-16)/24 {
+			fmt.Printf("    <corrupt file count %d>\n", count)
+			break
+		}
+		names := bytes.Split(d[16+24*count:], []byte{0})
+		fmt.Printf("    Page Size: %d\n", pagesize)
+		fmt.Printf("    %-18s %-18s %-12s %s\n", "Start", "End", "Offset", "Path")
+		for i := uint64(0); i < count; i++ {
+			e := d[16+24*i:]
+			var name string
+			if i < uint64(len(names)) {
+				name = string(names[i])
+			}
+			fmt.Printf("    %#016x %#016x %#010x %s\n", bo.Uint64(e), bo.Uint64(e[8:]), bo.Uint64(e[16:])*pagesize, name)
+		}
+	}
+}
+
+// fortifiable lists the libc functions that have a _chk variant
+var fortifiable = []string{
+	"confstr", "fdelt", "fgets", "fgets_unlocked", "fgetws", "fgetws_unlocked",
+	"fprintf", "fread", "fread_unlocked", "fwprintf", "getcwd", "getdomainname",
+	"getgroups", "gethostname", "getlogin_r", "gets", "getwd", "mbsnrtowcs",
+	"mbsrtowcs", "mbstowcs", "memcpy", "memmove", "mempcpy", "memset", "poll",
+	"ppoll", "pread", "pread64", "printf", "ptsname_r", "read", "readlink",
+	"readlinkat", "realpath", "recv", "recvfrom", "snprintf", "sprintf", "stpcpy",
+	"stpncpy", "strcat", "strcpy", "strncat", "strncpy", "swprintf", "syslog",
+	"ttyname_r", "vfprintf", "vfwprintf", "vprintf", "vsnprintf", "vsprintf",
+	"vswprintf", "vsyslog", "vwprintf", "wcpcpy", "wcpncpy", "wcrtomb", "wcscat",
+	"wcscpy", "wcsncat", "wcsncpy", "wcsnrtombs", "wcsrtombs", "wcstombs",
+	"wctomb", "wmemcpy", "wmemmove", "wmempcpy", "wmemset", "wprintf",
+}
+
+type secreport struct {
+	File        string `json:"file"`
+	Error       string `json:"error,omitempty"`
+	PIE         string `json:"pie"`
+	NX          bool   `json:"nx"`
+	RELRO       string `json:"relro"`
+	Canary      bool   `json:"canary"`
+	Fortified   int    `json:"fortified"`
+	Fortifiable int    `json:"fortifiable"`
+	IBT         bool   `json:"ibt"`
+	SHSTK       bool   `json:"shstk"`
+	RPATH       string `json:"rpath,omitempty"`
+	RUNPATH     string `json:"runpath,omitempty"`
+	Stripped    bool   `json:"stripped"`
+}
+
+type secsummary struct {
+	Files     int `json:"files"`
+	Errors    int `json:"errors"`
+	PIE       int `json:"pie"`
+	NX        int `json:"nx"`
+	FullRELRO int `json:"full_relro"`
+	Canary    int `json:"canary"`
+	Fortify   int `json:"fortify"`
+	IBT       int `json:"ibt"`
+	SHSTK     int `json:"shstk"`
+	RPATH     int `json:"rpath"`
+	Stripped  int `json:"stripped"`
+}
+
+func (r *secreport) fortify() string {
+	switch {
+	case r.Fortified > 0:
+		return "yes"
+	case r.Fortifiable > 0:
+		return "no"
+	}
+	return "n/a"
+}
+
+// findelf expands directories into the elf files under them,
+// files named directly are always checked
+func findelf(args []string) []string {
+	var files []string
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			warnf("%v", err)
+			continue
+		}
+		if !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				warnf("%v", err)
+				return nil
+			}
+			if d.Type().IsRegular() && iself(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+func iself(name string) bool {
+	fd, err := os.Open(name)
+	if err != nil {
+		return false
+	}
+	defer fd.Close()
+
+	var magic [4]byte
+	_, err = fd.Read(magic[:])
+	return err == nil && string(magic[:]) == elf.ELFMAG
+}
+
+func checksec(args []string) {
+	files := findelf(args)
+	reports := make([]secreport, len(files))
+
+	var wg sync.WaitGroup
+	work := make(chan int)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range work {
+				reports[n] = checkfile(files[n])
+			}
+		}()
+	}
+	for i := range files {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	var sum secsummary
+	for _, r := range reports {
+		sum.Files++
+		if r.Error != "" {
+			sum.Errors++
+			status = 1
+			continue
+		}
+		sum.PIE += btoi(r.PIE == "yes")
+		sum.NX += btoi(r.NX)
+		sum.FullRELRO += btoi(r.RELRO == "full")
+		sum.Canary += btoi(r.Canary)
+		sum.Fortify += btoi(r.Fortified > 0)
+		sum.IBT += btoi(r.IBT)
+		sum.SHSTK += btoi(r.SHSTK)
+		sum.RPATH += btoi(r.RPATH != "" || r.RUNPATH != "")
+		sum.Stripped += btoi(r.Stripped)
+	}
+
+	if *JSONflag {
+		j := struct {
+			Files   []secreport `json:"files"`
+			Summary secsummary  `json:"summary"`
+		}{reports, sum}
+		b, err := json.MarshalIndent(&j, "", "\t")
+		ck(err)
+		fmt.Printf("%s\n", b)
+		return
+	}
+
+	fmt.Printf("%-8s %-7s %-4s %-4s %-8s %-11s %-7s %-7s %-8s %s\n", "RELRO", "CANARY", "NX", "PIE", "FORTIFY", "CET", "RPATH", "RUNPATH", "STRIPPED", "FILE")
+	for _, r := range reports {
+		if r.Error != "" {
+			warnf("%s: %s", r.File, r.Error)
+			continue
+		}
+		var cet []string
+		if r.IBT {
+			cet = append(cet, "IBT")
+		}
+		if r.SHSTK {
+			cet = append(cet, "SHSTK")
+		}
+		if len(cet) == 0 {
+			cet = append(cet, "no")
+		}
+		fmt.Printf("%-8s %-7s %-4s %-4s %-8s %-11s %-7s %-7s %-8s %s\n",
+			r.RELRO, yesno(r.Canary), yesno(r.NX), r.PIE, r.fortify(), strings.Join(cet, ","),
+			yesno(r.RPATH != ""), yesno(r.RUNPATH != ""), yesno(r.Stripped), r.File)
+	}
+	fmt.Println()
+	fmt.Printf("%d files, %d errors, %d pie, %d nx, %d full relro, %d canary, %d fortify, %d ibt, %d shstk, %d rpath, %d stripped\n",
+		sum.Files, sum.Errors, sum.PIE, sum.NX, sum.FullRELRO, sum.Canary, sum.Fortify, sum.IBT, sum.SHSTK, sum.RPATH, sum.Stripped)
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func yesno(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func checkfile(name string) secreport {
+	r := secreport{File: name, RELRO: "no", PIE: "no"}
+	f, err := elfutil.Open(name)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	defer f.Close()
+
+	var interp, relro bool
+	for _, p := range f.Progs {
+		switch p.Type {
+		case elf.PT_INTERP:
+			interp = true
+		case elf.PT_GNU_RELRO:
+			relro = true
+		case elf.PT_GNU_STACK:
+			r.NX = p.Flags&elf.PF_X == 0
+		}
+	}
+
+	d, strtab := readdynamic(f)
+	var bindnow, pie bool
+	for _, e := range d {
+		switch e.Tag {
+		case elf.DT_BIND_NOW:
+			bindnow = true
+		case elf.DT_FLAGS:
+			bindnow = bindnow || elf.DynFlag(e.Val)&elf.DF_BIND_NOW != 0
+		case elf.DT_FLAGS_1:
+			bindnow = bindnow || elf.DynFlag1(e.Val)&elf.DF_1_NOW != 0
+			pie = elf.DynFlag1(e.Val)&elf.DF_1_PIE != 0
+		case elf.DT_RPATH:
+			r.RPATH = cstring(strtab, e.Val)
+		case elf.DT_RUNPATH:
+			r.RUNPATH = cstring(strtab, e.Val)
+		}
+	}
+	if relro {
+		r.RELRO = "partial"
+		if bindnow {
+			r.RELRO = "full"
+		}
+	}
+
+	switch f.Type {
+	case elf.ET_DYN:
+		r.PIE = "dso"
+		if pie || interp {
+			r.PIE = "yes"
+		}
+	case elf.ET_REL:
+		r.PIE = "rel"
+	}
+
+	r.Stripped = true
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_SYMTAB {
+			r.Stripped = false
+		}
+	}
+
+	names := make(map[string]bool)
+	dyn, _ := f.DynamicSymbols()
+	sym, _ := f.Symbols()
+	for _, y := range append(dyn, sym...) {
+		names[y.Name] = true
+	}
+	r.Canary = names["__stack_chk_fail"] || names["__stack_chk_guard"] || names["__intel_security_cookie"]
+	for _, fn := range fortifiable {
+		if names["__"+fn+"_chk"] {
+			r.Fortified++
+		} else if names[fn] {
+			r.Fortifiable++
+		}
+	}
+
+	if v, ok := noteproperty(f, GNU_PROPERTY_X86_FEATURE_1_AND); ok {
+		r.IBT = v&1 != 0
+		r.SHSTK = v&2 != 0
+	}
+	return r
+}
+
+// noteproperty finds a gnu property in the note sections or segments
+func noteproperty(f *elfutil.File, typ uint32) (uint32, bool) {
+	var n []note
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOTE {
+			n = append(n, readnotes(f, s.Data, s.Addralign)...)
+		}
+	}
+	if len(n) == 0 {
+		for _, p := range f.Progs {
+			if p.Type == elf.PT_NOTE {
+				n = append(n, readnotes(f, p.Data, p.Align)...)
+			}
+		}
+	}
+
+	bo := f.ByteOrder
+	align := uint64(wordsize(f))
+	for _, n := range n {
+		if n.Name != "GNU" || n.Type != 5 {
+			continue
+		}
+		d := n.Desc
+		for off := uint64(0); off+8 <= uint64(len(d)); {
+			t := bo.Uint32(d[off:])
+			size := uint64(bo.Uint32(d[off+4:]))
+			off += 8
+			if off+size > uint64(len(d)) {
+				break
+			}
+			if t == typ && size >= 4 {
+				return bo.Uint32(d[off:]), true
+			}
+			off = (off + size + align - 1) &^ (align - 1)
+		}
+	}
+	return 0, false
+}
+
+// not defined by debug/dwarf
+const (
+	AttrMIPSLinkageName = dwarf.Attr(0x2007)
+	AttrGNUDwoName      = dwarf.Attr(0x2130)
+)
+
+var dwarfLangs = map[int64]string{
+	0x01: "C89", 0x02: "C", 0x03: "Ada83", 0x04: "C++", 0x05: "Cobol74",
+	0x06: "Cobol85", 0x07: "Fortran77", 0x08: "Fortran90", 0x09: "Pascal83", 0x0a: "Modula2",
+	0x0b: "Java", 0x0c: "C99", 0x0d: "Ada95", 0x0e: "Fortran95", 0x0f: "PLI",
+	0x10: "ObjC", 0x11: "ObjC++", 0x12: "UPC", 0x13: "D", 0x14: "Python",
+	0x15: "OpenCL", 0x16: "Go", 0x17: "Modula3", 0x18: "Haskell", 0x19: "C++03",
+	0x1a: "C++11", 0x1b: "OCaml", 0x1c: "Rust", 0x1d: "C11", 0x1e: "Swift",
+	0x1f: "Julia", 0x20: "Dylan", 0x21: "C++14", 0x22: "Fortran03", 0x23: "Fortran08",
+	0x24: "RenderScript", 0x25: "BLISS", 0x26: "Kotlin", 0x27: "Zig", 0x28: "Crystal",
+	0x2a: "C++17", 0x2b: "C++20", 0x2c: "C17", 0x2d: "Fortran18", 0x2e: "Ada2005",
+	0x2f: "Ada2012", 0x8001: "Mips Assembler",
+}
+
+// debuginfo is the dwarf data for a file, possibly loaded from
+// a separate debug file, split units are loaded from their .dwo on demand
+type debuginfo struct {
+	data *dwarf.Data
+	path string
+	addr []byte
+	dwos map[dwarf.Offset]*dwarf.Data
+}
+
+func hasdwarf(f *elf.File) bool {
+	return f.Section(".debug_info") != nil || f.Section(".zdebug_info") != nil
+}
+
+func sectdata(f *elf.File, name string) []byte {
+	s := f.Section(name)
+	if s == nil {
+		return nil
+	}
+	b, err := s.Data()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func loaddwarf(f *elfutil.File, name string) (*debuginfo, error) {
+	ef := f.File
+	path := name
+	if !hasdwarf(ef) {
+		path = ""
+		for _, p := range debugfiles(f, name) {
+			xf, err := elf.Open(p)
+			if err != nil {
+				continue
+			}
+			if hasdwarf(xf) {
+				ef, path = xf, p
+				break
+			}
+			xf.Close()
+		}
+		if path == "" {
+			return nil, fmt.Errorf("%v: no dwarf data and no separate debug file found", name)
+		}
+		// the dwarf and .debug_addr data are read into memory below
+		defer ef.Close()
+	}
+
+	d, err := ef.DWARF()
+	if err != nil {
+		return nil, err
+	}
+	return &debuginfo{
+		data: d,
+		path: path,
+		addr: sectdata(ef, ".debug_addr"),
+		dwos: make(map[dwarf.Offset]*dwarf.Data),
+	}, nil
+}
+
+// debugfiles lists the places a separate debug file can be,
+// first by build-id then by the .gnu_debuglink name
+func debugfiles(f *elfutil.File, name string) []string {
+	var files []string
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_NOTE {
+			continue
+		}
+		for _, n := range readnotes(f, s.Data, s.Addralign) {
+			if n.Name == "GNU" && n.Type == 3 && len(n.Desc) > 1 {
+				id := fmt.Sprintf("%x", n.Desc)
+				files = append(files, filepath.Join(*Debugflag, ".build-id", id[:2], id[2:]+".debug"))
+			}
+		}
+	}
+
+	for _, s := range f.Sections {
+		if s.Name != ".gnu_debuglink" {
+			continue
+		}
+		link := cstring(s.Data, 0)
+		off := (len(link) + 4) &^ 3
+		if link == "" || off+4 > len(s.Data) {
+			break
+		}
+		crc := f.ByteOrder.Uint32(s.Data[off:])
+
+		dir, _ := filepath.Abs(filepath.Dir(name))
+		for _, p := range []string{
+			filepath.Join(dir, link),
+			filepath.Join(dir, ".debug", link),
+			filepath.Join(*Debugflag, dir, link),
+		} {
+			b, err := os.ReadFile(p)
+			if err == nil && crc32.ChecksumIEEE(b) == crc && p != name {
+				files = append(files, p)
+			}
+		}
+	}
+	return files
+}
+
+// splitunit loads the .dwo that holds the debug info for a skeleton unit,
+// the indexed sections are passed without their headers since the split
+// unit has no base attributes and the reader then indexes from offset 0
+func (di *debuginfo) splitunit(cu *dwarf.Entry) (*dwarf.Data, error) {
+	if d, ok := di.dwos[cu.Offset]; ok {
+		return d, nil
+	}
+
+	dwo, _ := cu.Val(dwarf.AttrDwoName).(string)
+	if dwo == "" {
+		if _, ok := cu.Val(AttrGNUDwoName).(string); ok {
+			return nil, fmt.Errorf("pre-dwarf5 (gnu) split units are not supported, rebuild with -gdwarf-5")
+		}
+		return nil, nil
+	}
+	var paths []string
+	if dir, _ := cu.Val(dwarf.AttrCompDir).(string); dir != "" && !filepath.IsAbs(dwo) {
+		paths = append(paths, filepath.Join(dir, dwo))
+	}
+	paths = append(paths, dwo, filepath.Join(filepath.Dir(di.path), filepath.Base(dwo)))
+
+	var (
+		f   *elf.File
+		err error
+	)
+	for _, p := range paths {
+		if f, err = elf.Open(p); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, err := dwarf.New(sectdata(f, ".debug_abbrev.dwo"), nil, nil, sectdata(f, ".debug_info.dwo"),
+		sectdata(f, ".debug_line.dwo"), nil, nil, sectdata(f, ".debug_str.dwo"))
+	if err != nil {
+		return nil, err
+	}
+
+	addrbase, _ := cu.Val(dwarf.AttrAddrBase).(int64)
+	sects := []struct {
+		name string
+		data []byte
+		hdr  int
+	}{
+		{".debug_str_offsets", sectdata(f, ".debug_str_offsets.dwo"), 8},
+		{".debug_rnglists", sectdata(f, ".debug_rnglists.dwo"), 12},
+		{".debug_addr", di.addr, int(addrbase)},
+	}
+	for _, s := range sects {
+		hdr := s.hdr
+		if s.name != ".debug_addr" && len(s.data) >= 4 && f.ByteOrder.Uint32(s.data) == 0xffffffff {
+			hdr += 8
+		}
+		if len(s.data) < hdr {
+			continue
+		}
+		if err := d.AddSection(s.name, s.data[hdr:]); err != nil {
+			return nil, err
+		}
+	}
+
+	di.dwos[cu.Offset] = d
+	return d, nil
+}
+
+// unitdata returns the data and top level entry that describe a unit,
+// which for skeleton units is the split unit in the .dwo
+func (di *debuginfo) unitdata(cu *dwarf.Entry) (*dwarf.Data, *dwarf.Entry) {
+	d, err := di.splitunit(cu)
+	if err != nil {
+		warnf("%v: %v", cu.Val(dwarf.AttrDwoName), err)
+	}
+	if d == nil {
+		return di.data, cu
+	}
+	e, err := d.Reader().Next()
+	if err != nil || e == nil {
+		warnf("%v: failed to read split unit: %v", cu.Val(dwarf.AttrDwoName), err)
+		return di.data, cu
+	}
+	return d, e
+}
+
+func isunit(t dwarf.Tag) bool {
+	switch t {
+	case dwarf.TagCompileUnit, dwarf.TagSkeletonUnit, dwarf.TagPartialUnit, dwarf.TagTypeUnit:
+		return true
+	}
+	return false
+}
+
+func eachunit(di *debuginfo, fn func(cu *dwarf.Entry)) {
+	r := di.data.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			warnf("Failed to read dwarf: %v", err)
+			return
+		}
+		if e == nil {
+			return
+		}
+		if isunit(e.Tag) {
+			fn(e)
+		}
+		r.SkipChildren()
+	}
+}
+
+func dumpunits(f *elfutil.File, name string) {
+	di, err := loaddwarf(f, name)
+	ck(err)
+
+	fmt.Printf("Compile Units: (%s)\n", di.path)
+	fmt.Println(strings.Repeat("-", 80))
+	eachunit(di, func(cu *dwarf.Entry) {
+		_, e := di.unitdata(cu)
+		fmt.Printf("Unit at offset %#x: %v\n", cu.Offset, cu.Tag)
+		fmt.Printf("    Name:     %v\n", e.Val(dwarf.AttrName))
+		fmt.Printf("    Producer: %v\n", e.Val(dwarf.AttrProducer))
+		if lang, ok := e.Val(dwarf.AttrLanguage).(int64); ok {
+			name, found := dwarfLangs[lang]
+			if !found {
+				name = fmt.Sprintf("%#x", lang)
+			}
+			fmt.Printf("    Language: %s\n", name)
+		}
+		fmt.Printf("    Comp Dir: %v\n", cu.Val(dwarf.AttrCompDir))
+		if dwo := cu.Val(dwarf.AttrDwoName); dwo != nil {
+			fmt.Printf("    DWO Name: %v\n", dwo)
+		}
+		if r, err := di.data.Ranges(cu); err == nil {
+			for _, r := range r {
+				fmt.Printf("    Range:    %#x-%#x\n", r[0], r[1])
+			}
+		}
+
+		lr, err := di.data.LineReader(cu)
+		if err != nil {
+			warnf("Failed to read line table for %v: %v", e.Val(dwarf.AttrName), err)
+		}
+		if lr != nil {
+			fmt.Printf("    Files:\n")
+			for i, f := range lr.Files() {
+				if f != nil {
+					fmt.Printf("        %-3d %s\n", i, f.Name)
+				}
+			}
+		}
+		fmt.Println()
+	})
+}
+
+func dumplines(f *elfutil.File, name string) {
+	di, err := loaddwarf(f, name)
+	ck(err)
+
+	eachunit(di, func(cu *dwarf.Entry) {
+		lr, err := di.data.LineReader(cu)
+		if err != nil {
+			warnf("Failed to read line table: %v", err)
+		}
+		if lr == nil {
+			return
+		}
+		_, e := di.unitdata(cu)
+		fmt.Printf("Line Table for %v:\n", e.Val(dwarf.AttrName))
+		fmt.Println(strings.Repeat("-", 80))
+		fmt.Printf("%-18s %6s %4s %-5s %s\n", "Address", "Line", "Col", "Flags", "File")
+
+		var le dwarf.LineEntry
+		for {
+			err := lr.Next(&le)
+			if err != nil {
+				break
+			}
+			var fl bytes.Buffer
+			for _, c := range []struct {
+				set bool
+				str string
+			}{
+				{le.IsStmt, "S"},
+				{le.BasicBlock, "B"},
+				{le.PrologueEnd, "P"},
+				{le.EpilogueBegin, "E"},
+				{le.EndSequence, "X"},
+			} {
+				if c.set {
+					fl.WriteString(c.str)
+				}
+			}
+			var file string
+			if le.File != nil {
+				file = le.File.Name
+			}
+			fmt.Printf("%#016x %6d %4d %-5s %s\n", le.Address, le.Line, le.Column, fl.String(), file)
+		}
+		fmt.Println()
+	})
+	fmt.Println("Key to Flags: S (statement), B (basic block), P (prologue end), E (epilogue begin), X (end sequence)")
+}
+
+// funcname follows abstract origins and specifications to the name of a
+// subprogram, preferring the demangled linkage name if -C was given
+func funcname(d *dwarf.Data, e *dwarf.Entry) string {
+	for i := 0; i < 8 && e != nil; i++ {
+		if *Cflag {
+			for _, a := range []dwarf.Attr{dwarf.AttrLinkageName, AttrMIPSLinkageName} {
+				if ln, ok := e.Val(a).(string); ok {
+					if cname := demangle.Cplus(ln, demangle.PARAMS|demangle.TYPES|demangle.VERBOSE); cname != "" {
+						return cname
+					}
+				}
+			}
+		}
+		if n, ok := e.Val(dwarf.AttrName).(string); ok {
+			return n
+		}
+
+		off, ok := e.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+		if !ok {
+			off, ok = e.Val(dwarf.AttrSpecification).(dwarf.Offset)
+		}
+		if !ok {
+			break
+		}
+		r := d.Reader()
+		r.Seek(off)
+		e, _ = r.Next()
+	}
+	return "??"
+}
+
+// inlinechain returns the subprogram containing pc followed by
+// the inlined subroutines down to the innermost one
+func inlinechain(d *dwarf.Data, cu *dwarf.Entry, pc uint64) []*dwarf.Entry {
+	var chain []*dwarf.Entry
+	r := d.Reader()
+	r.Seek(cu.Offset)
+	r.Next()
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil || isunit(e.Tag) {
+			break
+		}
+		if e.Tag != dwarf.TagSubprogram && e.Tag != dwarf.TagInlinedSubroutine {
+			continue
+		}
+
+		found := false
+		rs, _ := d.Ranges(e)
+		for _, r := range rs {
+			if r[0] <= pc && pc < r[1] {
+				found = true
+			}
+		}
+		if found {
+			chain = append(chain, e)
+		} else if e.Tag == dwarf.TagSubprogram {
+			r.SkipChildren()
+		}
+	}
+	return chain
+}
+
+func linefile(lr *dwarf.LineReader, idx int64) string {
+	if lr == nil {
+		return "??"
+	}
+	files := lr.Files()
+	if idx < 0 || idx >= int64(len(files)) || files[idx] == nil {
+		return "??"
+	}
+	return files[idx].Name
+}
+
+// seekline scans every sequence for the row covering pc, the line reader's
+// SeekPC gives up when the sequences are not sorted by address
+func seekline(lr *dwarf.LineReader, pc uint64) (dwarf.LineEntry, bool) {
+	var prev, le dwarf.LineEntry
+	if lr == nil {
+		return le, false
+	}
+	lr.Reset()
+	first := true
+	for lr.Next(&le) == nil {
+		if !first && !prev.EndSequence && prev.Address <= pc && pc < le.Address {
+			return prev, true
+		}
+		prev, first = le, false
+	}
+	return le, false
+}
+
+func addr2line(f *elfutil.File, name string, args []string) {
+	di, err := loaddwarf(f, name)
+	ck(err)
+
+	lookup := func(str string) {
+		pc, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(str), "0x"), 16, 64)
+		if err != nil {
+			warnf("invalid address %q", str)
+			return
+		}
+
+		cu, err := di.data.Reader().SeekPC(pc)
+		if err != nil {
+			fmt.Printf("%#x: ?? at ??:0\n", pc)
+			return
+		}
+
+		file, line := "??", 0
+		lr, _ := di.data.LineReader(cu)
+		if le, ok := seekline(lr, pc); ok && le.File != nil {
+			file, line = le.File.Name, le.Line
+		}
+
+		d, e := di.unitdata(cu)
+		chain := inlinechain(d, e, pc)
+		if len(chain) == 0 {
+			fmt.Printf("%#x: ?? at %s:%d\n", pc, file, line)
+			return
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			if i == len(chain)-1 {
+				fmt.Printf("%#x: %s at %s:%d\n", pc, funcname(d, chain[i]), file, line)
+			} else {
+				fmt.Printf("    inlined by %s at %s:%d\n", funcname(d, chain[i]), file, line)
+			}
+			cf, _ := chain[i].Val(dwarf.AttrCallFile).(int64)
+			cl, _ := chain[i].Val(dwarf.AttrCallLine).(int64)
+			file, line = linefile(lr, cf), int(cl)
+		}
+	}
+
+	if len(args) > 0 {
+		for _, arg := range args {
+			lookup(arg)
+		}
+		return
+	}
+	s := bufio.NewScanner(os.Stdin)
+	for s.Scan() {
+		for _, str := range strings.Fields(s.Text()) {
+			lookup(str)
+		}
+	}
+}
+
+// rawsect is a section header as stored in the file
+type rawsect struct {
+	Name      string
+	Type      elf.SectionType
+	Flags     elf.SectionFlag
+	Addr      uint64
+	Offset    uint64
+	Size      uint64
+	Link      uint32
+	Info      uint32
+	Addralign uint64
+	Entsize   uint64
+	Data      []byte
+	Index     int
+}
+
+// splitpairs parses a comma separated name=file list
+func splitpairs(str string) ([][2]string, error) {
+	var p [][2]string
+	if str == "" {
+		return p, nil
+	}
+	for _, s := range strings.Split(str, ",") {
+		i := strings.IndexByte(s, '=')
+		if i <= 0 || i == len(s)-1 {
+			return nil, fmt.Errorf("invalid section spec %q, want name=file", s)
+		}
+		p = append(p, [2]string{s[:i], s[i+1:]})
+	}
+	return p, nil
+}
+
+func findsect(sects []*rawsect, name string) *rawsect {
+	for _, s := range sects {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// patchelf rewrites the file with the requested section changes,
+// everything the loader sees stays where it is and the non-allocated
+// sections are laid out again after it followed by a new section header table
+func patchelf(f *elfutil.File, name, out string) error {
+	h, err := readheader(f, name)
+	if err != nil {
+		return err
+	}
+	if h.Shnum == 0 || h.Shstrndx >= uint16(elf.SHN_LORESERVE) {
+		return fmt.Errorf("%v: no section header table or extended section numbering", name)
+	}
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	// use the raw bytes so compressed sections are kept as they are
+	var sects []*rawsect
+	for i, s := range f.Sections {
+		var data []byte
+		if s.Type != elf.SHT_NOBITS && s.Type != elf.SHT_NULL {
+			if s.Offset+s.FileSize > uint64(len(buf)) {
+				return fmt.Errorf("%v: section %q is past the end of the file", name, s.Name)
+			}
+			data = buf[s.Offset : s.Offset+s.FileSize]
+		}
+		sects = append(sects, &rawsect{
+			s.Name, s.Type, s.Flags, s.Addr, s.Offset, s.FileSize, s.Link, s.Info, s.Addralign, s.Entsize, data, i,
+		})
+	}
+
+	repl, err := splitpairs(*Replaceflag)
+	if err != nil {
+		return err
+	}
+	for _, r := range repl {
+		s := findsect(sects, r[0])
+		if s == nil {
+			return fmt.Errorf("no section named %q", r[0])
+		}
+		if s.Type == elf.SHT_NOBITS {
+			return fmt.Errorf("section %q has no file data to replace", r[0])
+		}
+		data, err := os.ReadFile(r[1])
+		if err != nil {
+			return err
+		}
+		// allocated sections are mapped by the program headers, so they
+		// can only be overwritten in place and keep their size
+		if s.Flags&elf.SHF_ALLOC != 0 {
+			if uint64(len(data)) > s.Size {
+				return fmt.Errorf("allocated section %q is %d bytes, can't grow it to %d", s.Name, s.Size, len(data))
+			}
+			data = append(data, make([]byte, s.Size-uint64(len(data)))...)
+		}
+		s.Data, s.Size = data, uint64(len(data))
+	}
+
+	adds, err := splitpairs(*Addflag)
+	if err != nil {
+		return err
+	}
+	for _, a := range adds {
+		if findsect(sects, a[0]) != nil {
+			return fmt.Errorf("section %q already exists", a[0])
+		}
+		data, err := os.ReadFile(a[1])
+		if err != nil {
+			return err
+		}
+		typ := elf.SHT_PROGBITS
+		if strings.HasPrefix(a[0], ".note") {
+			typ = elf.SHT_NOTE
+		}
+		sects = append(sects, &rawsect{Name: a[0], Type: typ, Size: uint64(len(data)), Addralign: 1, Data: data, Index: -1})
+	}
+
+	removed := make(map[int]bool)
+	if *Removeflag != "" {
+		for _, n := range strings.Split(*Removeflag, ",") {
+			s := findsect(sects, n)
+			switch {
+			case s == nil:
+				return fmt.Errorf("no section named %q", n)
+			case s.Flags&elf.SHF_ALLOC != 0:
+				return fmt.Errorf("can't remove allocated section %q", n)
+			case s.Index == int(h.Shstrndx):
+				return fmt.Errorf("can't remove the section name table %q", n)
+			}
+			removed[s.Index] = true
+		}
+		// like objcopy the relocations for a removed section and its
+		// section symbols go with it, so debug sections can be taken
+		// out of relocatable objects
+		for _, s := range sects {
+			if (s.Type == elf.SHT_REL || s.Type == elf.SHT_RELA) && s.Index >= 0 && s.Info != 0 && removed[int(s.Info)] {
+				removed[s.Index] = true
+			}
+		}
+		if err := dropsectsyms(f, sects, removed); err != nil {
+			return err
+		}
+	}
+
+	// renumber the sections that remain and fix up everything that refers to them
+	remap := make(map[uint32]uint32)
+	var keep []*rawsect
+	for _, s := range sects {
+		if s.Index >= 0 && removed[s.Index] {
+			continue
+		}
+		if s.Index >= 0 {
+			remap[uint32(s.Index)] = uint32(len(keep))
+		}
+		keep = append(keep, s)
+	}
+	fix := func(s *rawsect, what string, idx uint32) (uint32, error) {
+		if idx == 0 {
+			return 0, nil
+		}
+		n, ok := remap[idx]
+		if !ok {
+			return 0, fmt.Errorf("section %q %s refers to removed section %d", s.Name, what, idx)
+		}
+		return n, nil
+	}
+	for _, s := range keep {
+		if s.Link, err = fix(s, "link", s.Link); err != nil {
+			return err
+		}
+		if s.Flags&elf.SHF_INFO_LINK != 0 || s.Type == elf.SHT_REL || s.Type == elf.SHT_RELA {
+			if s.Info, err = fix(s, "info", s.Info); err != nil {
+				return err
+			}
+		}
+		if err := remapdata(f, s, func(idx uint32) (uint32, error) { return fix(s, "contents", idx) }); err != nil {
+			return err
+		}
+	}
+
+	var shstrtab bytes.Buffer
+	shstrtab.WriteByte(0)
+	names := make([]uint32, len(keep))
+	for i, s := range keep {
+		if s.Name != "" {
+			names[i] = uint32(shstrtab.Len())
+			shstrtab.WriteString(s.Name)
+			shstrtab.WriteByte(0)
+		}
+	}
+	strndx := remap[uint32(h.Shstrndx)]
+	keep[strndx].Data = shstrtab.Bytes()
+	keep[strndx].Size = uint64(shstrtab.Len())
+
+	// keep everything up to the end of the loaded data untouched
+	var end uint64 = uint64(h.Phoff) + uint64(h.Phnum)*uint64(h.Phentsize)
+	for _, p := range f.Progs {
+		end = max(end, p.Off+p.Filesz)
+	}
+	for _, s := range keep {
+		if s.Flags&elf.SHF_ALLOC != 0 && s.Type != elf.SHT_NOBITS && s.Index >= 0 {
+			end = max(end, s.Offset+s.Size)
+		}
+	}
+	if end > uint64(len(buf)) {
+		return fmt.Errorf("%v: truncated file", name)
+	}
+	w := bytes.NewBuffer(append([]byte(nil), buf[:end]...))
+	pad := func(align uint64) {
+		if align > 1 {
+			for uint64(w.Len())%align != 0 {
+				w.WriteByte(0)
+			}
+		}
+	}
+
+	for _, s := range keep {
+		switch {
+		case s.Type == elf.SHT_NULL || s.Type == elf.SHT_NOBITS:
+		case s.Flags&elf.SHF_ALLOC != 0 && s.Index >= 0:
+			copy(w.Bytes()[s.Offset:], s.Data)
+		default:
+			pad(s.Addralign)
+			s.Offset = uint64(w.Len())
+			w.Write(s.Data)
+		}
+	}
+
+	pad(uint64(wordsize(f)))
+	shoff := uint64(w.Len())
+	for i, s := range keep {
+		var err error
+		if f.Class == elf.ELFCLASS64 {
+			err = binary.Write(w, f.ByteOrder, &elf.Section64{
+				Name: names[i], Type: uint32(s.Type), Flags: uint64(s.Flags), Addr: s.Addr, Off: s.Offset,
+				Size: s.Size, Link: s.Link, Info: s.Info, Addralign: s.Addralign, Entsize: s.Entsize,
+			})
+		} else {
+			err = binary.Write(w, f.ByteOrder, &elf.Section32{
+				Name: names[i], Type: uint32(s.Type), Flags: uint32(s.Flags), Addr: uint32(s.Addr), Off: uint32(s.Offset),
+				Size: uint32(s.Size), Link: s.Link, Info: s.Info, Addralign: uint32(s.Addralign), Entsize: uint32(s.Entsize),
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	b := w.Bytes()
+	if f.Class == elf.ELFCLASS64 {
+		f.ByteOrder.PutUint64(b[0x28:], shoff)
+		f.ByteOrder.PutUint16(b[0x3a:], uint16(binary.Size(elf.Section64{})))
+		f.ByteOrder.PutUint16(b[0x3c:], uint16(len(keep)))
+		f.ByteOrder.PutUint16(b[0x3e:], uint16(strndx))
+	} else {
+		f.ByteOrder.PutUint32(b[0x20:], uint32(shoff))
+		f.ByteOrder.PutUint16(b[0x2e:], uint16(binary.Size(elf.Section32{})))
+		f.ByteOrder.PutUint16(b[0x30:], uint16(len(keep)))
+		f.ByteOrder.PutUint16(b[0x32:], uint16(strndx))
+	}
+
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(name); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	return os.WriteFile(out, b, mode)
+}
+
+// remapdata renumbers the section indexes stored in symbol tables and groups
+// dropsectsyms removes the section symbols of removed sections from the
+// symbol tables and renumbers the relocations and groups that use them
+func dropsectsyms(f *elfutil.File, sects []*rawsect, removed map[int]bool) error {
+	bo := f.ByteOrder
+	size, infoff, shndxoff := elf.Sym32Size, 12, 14
+	relsize, relasize, relinfo := 8, 12, 4
+	if f.Class == elf.ELFCLASS64 {
+		size, infoff, shndxoff = elf.Sym64Size, 4, 6
+		relsize, relasize, relinfo = 16, 24, 8
+	}
+
+	for _, st := range sects {
+		if st.Type != elf.SHT_SYMTAB || st.Index < 0 || removed[st.Index] {
+			continue
+		}
+		symmap := make(map[uint32]uint32)
+		dropped := make(map[uint32]string)
+		var data []byte
+		var locals uint32
+		for i := 0; i+size <= len(st.Data); i += size {
+			e := st.Data[i : i+size]
+			idx := elf.SectionIndex(bo.Uint16(e[shndxoff:]))
+			old := uint32(i / size)
+			if elf.ST_TYPE(e[infoff]) == elf.STT_SECTION && idx != 0 && idx < elf.SHN_LORESERVE && removed[int(idx)] {
+				dropped[old] = sects[idx].Name
+				continue
+			}
+			if old < st.Info {
+				locals++
+			}
+			symmap[old] = uint32(len(data) / size)
+			data = append(data, e...)
+		}
+		if len(data) == len(st.Data) {
+			continue
+		}
+
+		for _, s := range sects {
+			if s.Index < 0 || removed[s.Index] || s.Link != uint32(st.Index) {
+				continue
+			}
+			switch s.Type {
+			case elf.SHT_SYMTAB_SHNDX:
+				return fmt.Errorf("can't drop symbols from %q, it has extended section indexes", st.Name)
+
+			case elf.SHT_GROUP:
+				n, ok := symmap[s.Info]
+				if !ok {
+					return fmt.Errorf("group %q is signed by the symbol of removed section %q", s.Name, dropped[s.Info])
+				}
+				s.Info = n
+
+			case elf.SHT_REL, elf.SHT_RELA:
+				es := relsize
+				if s.Type == elf.SHT_RELA {
+					es = relasize
+				}
+				rel := append([]byte(nil), s.Data...)
+				for i := 0; i+es <= len(rel); i += es {
+					var sym uint32
+					if f.Class == elf.ELFCLASS64 {
+						sym = uint32(bo.Uint64(rel[i+relinfo:]) >> 32)
+					} else {
+						sym = bo.Uint32(rel[i+relinfo:]) >> 8
+					}
+					n, ok := symmap[sym]
+					if !ok {
+						return fmt.Errorf("relocations in %q refer to removed section %q", s.Name, dropped[sym])
+					}
+					if f.Class == elf.ELFCLASS64 {
+						info := bo.Uint64(rel[i+relinfo:])
+						bo.PutUint64(rel[i+relinfo:], uint64(n)<<32|info&0xffffffff)
+					} else {
+						info := bo.Uint32(rel[i+relinfo:])
+						bo.PutUint32(rel[i+relinfo:], n<<8|info&0xff)
+					}
+				}
+				s.Data = rel
+			}
+		}
+		st.Data, st.Size, st.Info = data, uint64(len(data)), locals
+	}
+	return nil
+}
+
+func remapdata(f *elfutil.File, s *rawsect, fix func(uint32) (uint32, error)) error {
+	bo := f.ByteOrder
+	switch s.Type {
+	case elf.SHT_SYMTAB, elf.SHT_DYNSYM:
+		size, off := elf.Sym32Size, 14
+		if f.Class == elf.ELFCLASS64 {
+			size, off = elf.Sym64Size, 6
+		}
+		data := append([]byte(nil), s.Data...)
+		for i := 0; i+size <= len(data); i += size {
+			idx := bo.Uint16(data[i+off:])
+			if idx == 0 || elf.SectionIndex(idx) >= elf.SHN_LORESERVE {
+				continue
+			}
+			n, err := fix(uint32(idx))
+			if err != nil {
+				return err
+			}
+			bo.PutUint16(data[i+off:], uint16(n))
+		}
+		s.Data = data
+
+	case elf.SHT_GROUP:
+		data := append([]byte(nil), s.Data...)
+		for i := 4; i+4 <= len(data); i += 4 {
+			n, err := fix(bo.Uint32(data[i:]))
+			if err != nil {
+				return err
+			}
+			bo.PutUint32(data[i:], n)
+		}
+		s.Data = data
+	}
+	return nil
+}
+
+type sizeentry struct {
+	Name    string `json:"name"`
+	Section string `json:"section,omitempty"`
+	File    string `json:"file,omitempty"`
+	Old     uint64 `json:"old"`
+	New     uint64 `json:"new"`
+	Delta   int64  `json:"delta"`
+}
+
+type sizeinfo struct {
+	filesize uint64
+	loadsize uint64
+	sects    map[string]uint64
+	syms     map[string]*sizeentry
+	dwarf    bool
+}
+
+type unitrange struct {
+	lo, hi uint64
+	name   string
+}
+
+// unitranges returns the address ranges of the compile units sorted by address
+func unitranges(f *elfutil.File, name string) []unitrange {
+	di, err := loaddwarf(f, name)
+	if err != nil {
+		return nil
+	}
+	var r []unitrange
+	eachunit(di, func(cu *dwarf.Entry) {
+		_, e := di.unitdata(cu)
+		n, _ := e.Val(dwarf.AttrName).(string)
+		rs, _ := di.data.Ranges(cu)
+		for _, x := range rs {
+			r = append(r, unitrange{x[0], x[1], n})
+		}
+	})
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].lo < r[j].lo
+	})
+	return r
+}
+
+func unitfile(r []unitrange, addr uint64) string {
+	i := sort.Search(len(r), func(i int) bool {
+		return r[i].lo > addr
+	}) - 1
+	if i < 0 || addr >= r[i].hi {
+		return ""
+	}
+	return r[i].name
+}
+
+func readsizes(name string) *sizeinfo {
+	f, err := elfutil.Open(name)
+	ck(err)
+	defer f.Close()
+
+	si := &sizeinfo{
+		sects: make(map[string]uint64),
+		syms:  make(map[string]*sizeentry),
+	}
+	if fi, err := os.Stat(name); err == nil {
+		si.filesize = uint64(fi.Size())
+	}
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NULL {
+			continue
+		}
+		si.sects[s.Name] += s.Size
+		if s.Flags&elf.SHF_ALLOC != 0 {
+			si.loadsize += s.Size
+		}
+	}
+
+	sym, err := f.Symbols()
+	if err != nil {
+		sym, _ = f.DynamicSymbols()
+	}
+	units := unitranges(f, name)
+	si.dwarf = len(units) > 0
+	for _, y := range sym {
+		if y.Size == 0 || y.Section == elf.SHN_UNDEF || y.Section >= elf.SHN_LORESERVE || int(y.Section) >= len(f.Sections) {
+			continue
+		}
+		switch elf.ST_TYPE(y.Info) {
+		case elf.STT_SECTION, elf.STT_FILE:
+			continue
+		}
+
+		key := y.Name
+		if cname := demangle.Cplus(key, demangle.PARAMS|demangle.TYPES|demangle.VERBOSE); cname != "" {
+			key = cname
+		}
+		e := si.syms[key]
+		if e == nil {
+			e = &sizeentry{Name: key, Section: f.Sections[y.Section].Name, File: unitfile(units, y.Value)}
+			si.syms[key] = e
+		}
+		e.Old += y.Size
+	}
+	return si
+}
+
+func sortdelta(p []sizeentry) {
+	sort.SliceStable(p, func(i, j int) bool {
+		if p[i].Delta != p[j].Delta {
+			return p[i].Delta > p[j].Delta
+		}
+		return p[i].Name < p[j].Name
+	})
+}
+
+// totals sums the symbol changes by the key chosen from each entry
+func totals(p []sizeentry, key func(*sizeentry) string) []sizeentry {
+	m := make(map[string]*sizeentry)
+	var keys []string
+	for i := range p {
+		k := key(&p[i])
+		t := m[k]
+		if t == nil {
+			t = &sizeentry{Name: k}
+			m[k] = t
+			keys = append(keys, k)
+		}
+		t.Old += p[i].Old
+		t.New += p[i].New
+		t.Delta += p[i].Delta
+	}
+	var r []sizeentry
+	for _, k := range keys {
+		if m[k].Delta != 0 {
+			r = append(r, *m[k])
+		}
+	}
+	sortdelta(r)
+	return r
+}
+
+func sizediff(a, b string) {
+	x := readsizes(a)
+	y := readsizes(b)
+
+	var sects []sizeentry
+	for n := range x.sects {
+		if _, ok := y.sects[n]; !ok {
+			y.sects[n] = 0
+		}
+	}
+	for n, v := range y.sects {
+		e := sizeentry{Name: n, Old: x.sects[n], New: v}
+		e.Delta = int64(e.New) - int64(e.Old)
+		if e.Delta != 0 {
+			sects = append(sects, e)
+		}
+	}
+	sortdelta(sects)
+
+	// symbols only in the old build get their size moved over to the old column
+	var syms []sizeentry
+	for n, e := range x.syms {
+		if _, ok := y.syms[n]; !ok {
+			y.syms[n] = &sizeentry{Name: n, Section: e.Section, File: e.File}
+		}
+	}
+	for n, e := range y.syms {
+		s := *e
+		s.New = e.Old
+		s.Old = 0
+		if o := x.syms[n]; o != nil {
+			s.Old = o.Old
+			if s.File == "" {
+				s.File = o.File
+			}
+		}
+		s.Delta = int64(s.New) - int64(s.Old)
+		if s.Delta != 0 {
+			syms = append(syms, s)
+		}
+	}
+	sortdelta(syms)
+
+	bysect := totals(syms, func(e *sizeentry) string { return e.Section })
+	var byfile []sizeentry
+	if x.dwarf || y.dwarf {
+		byfile = totals(syms, func(e *sizeentry) string {
+			if e.File == "" {
+				return "<unknown>"
+			}
+			return e.File
+		})
+	}
+	total := []sizeentry{
+		{Name: "file", Old: x.filesize, New: y.filesize, Delta: int64(y.filesize) - int64(x.filesize)},
+		{Name: "loaded", Old: x.loadsize, New: y.loadsize, Delta: int64(y.loadsize) - int64(x.loadsize)},
+	}
+
+	if *JSONflag {
+		j := struct {
+			Old           string      `json:"old"`
+			New           string      `json:"new"`
+			Total         []sizeentry `json:"total"`
+			Sections      []sizeentry `json:"sections"`
+			Symbols       []sizeentry `json:"symbols"`
+			SectionTotals []sizeentry `json:"section_totals"`
+			FileTotals    []sizeentry `json:"file_totals,omitempty"`
+		}{a, b, total, sects, syms, bysect, byfile}
+		buf, err := json.MarshalIndent(&j, "", "\t")
+		ck(err)
+		fmt.Printf("%s\n", buf)
+		return
+	}
+
+	table := func(title string, p []sizeentry, detail bool) {
+		if len(p) == 0 {
+			return
+		}
+		fmt.Printf("%s: (%d)\n", title, len(p))
+		fmt.Println(strings.Repeat("-", 80))
+		if detail {
+			fmt.Printf("%10s %10s %10s %-16s %s\n", "Delta", "Old", "New", "Section", "Name")
+		} else {
+			fmt.Printf("%10s %10s %10s %s\n", "Delta", "Old", "New", "Name")
+		}
+		for _, e := range p {
+			if detail {
+				fmt.Printf("%+10d %10d %10d %-16s %s\n", e.Delta, e.Old, e.New, e.Section, e.Name)
+			} else {
+				fmt.Printf("%+10d %10d %10d %s\n", e.Delta, e.Old, e.New, e.Name)
+			}
+		}
+		fmt.Println()
+	}
+
+	var grown, shrunk []sizeentry
+	for _, e := range syms {
+		if e.Delta > 0 {
+			grown = append(grown, e)
+		} else {
+			shrunk = append(shrunk, e)
+		}
+	}
+	sort.SliceStable(shrunk, func(i, j int) bool {
+		return shrunk[i].Delta < shrunk[j].Delta
+	})
+
+	table("Total", total, false)
+	table("Sections", sects, false)
+	table("Symbols Grown", grown, true)
+	table("Symbols Shrunk", shrunk, true)
+	table("Symbol Totals by Section", bysect, false)
+	table("Symbol Totals by File", byfile, false)
+}
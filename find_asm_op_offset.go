@@ -0,0 +1,450 @@
+// find instructions that access memory at a given displacement, the reverse of gen_asm_op_offset
+package main
+
+import (
+	"bufio"
+	"debug/elf"
+	"debug/pe"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+type Text struct {
+	Name string
+	Addr uint64
+	Data []byte
+}
+
+type Func struct {
+	Name string
+	Addr uint64
+	Size uint64
+}
+
+type Image struct {
+	Arch  string
+	Texts []Text
+	Funcs []Func
+}
+
+type Hit struct {
+	Func *Func
+	Base string
+	Off  int64
+	Addr uint64
+	Inst string
+}
+
+var flags struct {
+	arch    string
+	offfile string
+	sect    string
+	syntax  string
+	stack   bool
+}
+
+var bout = bufio.NewWriter(os.Stdout)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("find_asm_op_offset: ")
+
+	parseflags()
+
+	offs := make(map[int64][]string)
+	for _, arg := range flag.Args()[1:] {
+		v, err := strconv.ParseInt(arg, 0, 64)
+		ck(err)
+		offs[v] = append(offs[v], "")
+	}
+	if flags.offfile != "" {
+		err := getoffs(flags.offfile, offs)
+		ck(err)
+	}
+	if len(offs) == 0 {
+		log.Fatal("no offsets specified")
+	}
+
+	img, err := load(flag.Arg(0))
+	ck(err)
+	if flags.arch != "" {
+		img.Arch = flags.arch
+	}
+
+	var hits []Hit
+	for _, t := range img.Texts {
+		if flags.sect != "" && t.Name != flags.sect {
+			continue
+		}
+		switch img.Arch {
+		case "amd64", "386":
+			hits = append(hits, scanx86(img, t, offs)...)
+		case "arm64":
+			hits = append(hits, scanarm64(img, t, offs)...)
+		default:
+			log.Fatalf("unsupported architecture %q", img.Arch)
+		}
+	}
+
+	report(hits, offs)
+	bout.Flush()
+}
+
+func parseflags() {
+	flag.StringVar(&flags.arch, "a", "", "override architecture [amd64 | 386 | arm64]")
+	flag.StringVar(&flags.offfile, "f", "", "read offsets from a mksfo offset file")
+	flag.StringVar(&flags.sect, "s", "", "only scan the named section")
+	flag.BoolVar(&flags.stack, "S", false, "include stack pointer relative accesses")
+	flag.StringVar(&flags.syntax, "y", "intel", "x86 syntax [intel | gnu | go]")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: find_asm_op_offset [options] <binary> [offset ...]")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func ck(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// getoffs reads offsets in the format mksfo accepts (name type offset),
+// entries without an offset are ignored
+func getoffs(name string, offs map[int64][]string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var (
+			name, typ string
+			off       int64
+		)
+		n, _ := fmt.Sscanf(s.Text(), "%v %v %v", &name, &typ, &off)
+		if n == 3 && off >= 0 {
+			offs[off] = append(offs[off], name)
+		}
+	}
+	return s.Err()
+}
+
+func load(name string) (*Image, error) {
+	img, err := loadelf(name)
+	if err == nil {
+		return img, nil
+	}
+	// a matching magic means the file is an elf, so report why it failed
+	if iself(name) {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+	if _, ok := err.(*elf.FormatError); !ok {
+		return nil, err
+	}
+	img, xerr := loadpe(name)
+	if xerr == nil {
+		return img, nil
+	}
+	return nil, fmt.Errorf("%v: not an ELF or PE file", name)
+}
+
+func iself(name string) bool {
+	f, err := os.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var magic [4]byte
+	_, err = io.ReadFull(f, magic[:])
+	return err == nil && string(magic[:]) == elf.ELFMAG
+}
+
+func loadelf(name string) (*Image, error) {
+	f, err := elf.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img := &Image{}
+	switch f.Machine {
+	case elf.EM_X86_64:
+		img.Arch = "amd64"
+	case elf.EM_386:
+		img.Arch = "386"
+	case elf.EM_AARCH64:
+		img.Arch = "arm64"
+	default:
+		img.Arch = f.Machine.String()
+	}
+
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_PROGBITS || s.Flags&elf.SHF_EXECINSTR == 0 {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, err
+		}
+		img.Texts = append(img.Texts, Text{s.Name, s.Addr, data})
+	}
+
+	syms, _ := f.Symbols()
+	dyns, _ := f.DynamicSymbols()
+	for _, y := range append(syms, dyns...) {
+		if elf.ST_TYPE(y.Info) == elf.STT_FUNC && y.Value != 0 {
+			img.Funcs = append(img.Funcs, Func{y.Name, y.Value, y.Size})
+		}
+	}
+	img.sortfuncs()
+	return img, nil
+}
+
+func loadpe(name string) (*Image, error) {
+	f, err := pe.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img := &Image{}
+	switch f.Machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		img.Arch = "amd64"
+	case pe.IMAGE_FILE_MACHINE_I386:
+		img.Arch = "386"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		img.Arch = "arm64"
+	default:
+		img.Arch = fmt.Sprintf("pe-%#x", f.Machine)
+	}
+
+	var imgbase uint64
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imgbase = uint64(h.ImageBase)
+	case *pe.OptionalHeader64:
+		imgbase = h.ImageBase
+	}
+
+	for _, s := range f.Sections {
+		if s.Characteristics&(pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE) == 0 {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, err
+		}
+		if uint32(len(data)) > s.VirtualSize && s.VirtualSize != 0 {
+			data = data[:s.VirtualSize]
+		}
+		img.Texts = append(img.Texts, Text{s.Name, imgbase + uint64(s.VirtualAddress), data})
+	}
+
+	for _, y := range f.Symbols {
+		if y.SectionNumber <= 0 || int(y.SectionNumber) > len(f.Sections) || y.Type&0xf0 != 0x20 {
+			continue
+		}
+		s := f.Sections[y.SectionNumber-1]
+		img.Funcs = append(img.Funcs, Func{y.Name, imgbase + uint64(s.VirtualAddress) + uint64(y.Value), 0})
+	}
+	img.sortfuncs()
+	return img, nil
+}
+
+// sortfuncs orders the functions by address, drops aliases and
+// fills in missing sizes with the distance to the next function
+func (img *Image) sortfuncs() {
+	p := img.Funcs
+	sort.SliceStable(p, func(i, j int) bool {
+		return p[i].Addr < p[j].Addr
+	})
+
+	var r []Func
+	for i := range p {
+		if len(r) > 0 && r[len(r)-1].Addr == p[i].Addr {
+			continue
+		}
+		r = append(r, p[i])
+	}
+	for i := range r {
+		if r[i].Size == 0 && i+1 < len(r) {
+			r[i].Size = r[i+1].Addr - r[i].Addr
+		}
+	}
+	img.Funcs = r
+}
+
+func (img *Image) lookup(addr uint64) *Func {
+	p := img.Funcs
+	i := sort.Search(len(p), func(i int) bool {
+		return p[i].Addr > addr
+	}) - 1
+	if i < 0 {
+		return nil
+	}
+	if p[i].Size != 0 && addr >= p[i].Addr+p[i].Size {
+		return nil
+	}
+	return &p[i]
+}
+
+func scanx86(img *Image, t Text, offs map[int64][]string) []Hit {
+	bits := 64
+	if img.Arch == "386" {
+		bits = 32
+	}
+
+	var hits []Hit
+	buf := t.Data
+	pc := t.Addr
+	for len(buf) > 0 {
+		inst, err := x86asm.Decode(buf, bits)
+		if err != nil {
+			buf = buf[1:]
+			pc++
+			continue
+		}
+
+		for _, a := range inst.Args {
+			m, ok := a.(x86asm.Mem)
+			if !ok || m.Base == x86asm.RIP || m.Base == x86asm.EIP || (m.Base == 0 && m.Index == 0) {
+				continue
+			}
+			if _, found := offs[m.Disp]; !found {
+				continue
+			}
+			if !flags.stack && (m.Base == x86asm.RSP || m.Base == x86asm.ESP) {
+				continue
+			}
+
+			base := "-"
+			if m.Base != 0 {
+				base = m.Base.String()
+			}
+
+			var op string
+			switch flags.syntax {
+			case "gnu":
+				op = x86asm.GNUSyntax(inst, pc, nil)
+			case "go":
+				op = inst.String()
+			default:
+				op = x86asm.IntelSyntax(inst, pc, nil)
+			}
+			hits = append(hits, Hit{img.lookup(pc), base, m.Disp, pc, op})
+		}
+
+		buf = buf[inst.Len:]
+		pc += uint64(inst.Len)
+	}
+	return hits
+}
+
+func scanarm64(img *Image, t Text, offs map[int64][]string) []Hit {
+	var hits []Hit
+	buf := t.Data
+	pc := t.Addr
+	for ; len(buf) >= 4; buf, pc = buf[4:], pc+4 {
+		inst, err := arm64asm.Decode(buf)
+		if err != nil {
+			continue
+		}
+
+		for _, a := range inst.Args {
+			m, ok := a.(arm64asm.MemImmediate)
+			if !ok || m.Mode != arm64asm.AddrOffset {
+				continue
+			}
+
+			// the immediate is not exported, so recover it from the [base,#imm] form
+			var disp int64
+			str := m.String()
+			if i := strings.Index(str, ",#"); i >= 0 {
+				disp, _ = strconv.ParseInt(strings.TrimSuffix(str[i+2:], "]"), 0, 64)
+			}
+			if _, found := offs[disp]; !found {
+				continue
+			}
+			if !flags.stack && m.Base == arm64asm.RegSP(arm64asm.SP) {
+				continue
+			}
+			hits = append(hits, Hit{img.lookup(pc), m.Base.String(), disp, pc, arm64asm.GNUSyntax(inst)})
+		}
+	}
+	return hits
+}
+
+func report(hits []Hit, offs map[int64][]string) {
+	funcaddr := func(h *Hit) uint64 {
+		if h.Func == nil {
+			return 0
+		}
+		return h.Func.Addr
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := &hits[i], &hits[j]
+		if x, y := funcaddr(a), funcaddr(b); x != y {
+			return x < y
+		}
+		if a.Base != b.Base {
+			return a.Base < b.Base
+		}
+		return a.Addr < b.Addr
+	})
+
+	var (
+		fn   *Func
+		base string
+	)
+	for i := range hits {
+		h := &hits[i]
+		if i == 0 || h.Func != fn {
+			fn = h.Func
+			base = ""
+			if fn == nil {
+				fmt.Fprintf(bout, "<unknown>:\n")
+			} else {
+				fmt.Fprintf(bout, "%s (%#x):\n", fn.Name, fn.Addr)
+			}
+		}
+		if h.Base != base {
+			base = h.Base
+			fmt.Fprintf(bout, "    %s:\n", base)
+		}
+
+		var names []string
+		for _, n := range offs[h.Off] {
+			if n != "" {
+				names = append(names, n)
+			}
+		}
+		label := fmt.Sprintf("%+#x", h.Off)
+		if len(names) > 0 {
+			label += " (" + strings.Join(names, ", ") + ")"
+		}
+		fmt.Fprintf(bout, "        %#016x %-32s %s\n", h.Addr, label, h.Inst)
+	}
+	fmt.Fprintf(bout, "%d matches\n", len(hits))
+}
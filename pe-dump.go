@@ -1,14 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/md5"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"math"
+	"math/big"
+	"math/bits"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf16"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/qeedquan/go-media/debug/peutil"
@@ -20,6 +39,92 @@ var (
 	vflag = flag.Bool("v", false, "dump everything")
 	yflag = flag.Bool("y", false, "dump debug symbols")
 	rflag = flag.String("r", "", "dump all section data into directory")
+	xflag = flag.String("x", "", "extract all resources into directory")
+	eflag = flag.Bool("e", false, "dump exception data and unwind info")
+	Fflag = flag.String("F", "", "write function boundaries from exception data to file")
+	oflag = flag.String("o", "", "extract the overlay into file")
+	jflag = flag.Bool("json", false, "dump in json format")
+)
+
+var (
+	dllChars = []struct {
+		bit uint32
+		str string
+	}{
+		{peutil.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE, "IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE"},
+		{peutil.IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY, "IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY"},
+		{peutil.IMAGE_DLLCHARACTERISTICS_NX_COMPAT, "IMAGE_DLLCHARACTERISTICS_NX_COMPAT"},
+		{peutil.IMAGE_DLLCHARACTERISTICS_NO_ISOLATION, "IMAGE_DLLCHARACTERISTICS_NO_ISOLATION"},
+		{peutil.IMAGE_DLLCHARACTERISTICS_NO_SEH, "IMAGE_DLLCHARACTERISTICS_NO_SEH"},
+		{peutil.IMAGE_DLLCHARACTERISTICS_NO_BIND, "IMAGE_DLLCHARACTERISTICS_NO_BIND"},
+		{peutil.IMAGE_DLLCHARACTERISTICS_WDM_DRIVER, "IMAGE_DLLCHARACTERISTICS_WDM_DRIVER"},
+		{peutil.IMAGE_DLLCHARACTERISTICS_TERMINAL_SERVER_AWARE, "IMAGE_DLLCHARACTERISTICS_TERMINAL_SERVER_AWARE"},
+	}
+
+	sectionChars = []struct {
+		bit uint32
+		str string
+	}{
+		{peutil.IMAGE_SCN_TYPE_NO_PAD, "IMAGE_SCN_TYPE_NO_PAD"},
+		{peutil.IMAGE_SCN_CNT_CODE, "IMAGE_SCN_CNT_CODE"},
+		{peutil.IMAGE_SCN_CNT_INITIALIZED_DATA, "IMAGE_SCN_CNT_INITIALIZED_DATA"},
+		{peutil.IMAGE_SCN_CNT_UNINITIALIZED_DATA, "IMAGE_SCN_CNT_UNINITIALIZED_DATA"},
+		{peutil.IMAGE_SCN_LNK_OTHER, "IMAGE_SCN_LNK_OTHER"},
+		{peutil.IMAGE_SCN_LNK_INFO, "IMAGE_SCN_LNK_INFO"},
+		{peutil.IMAGE_SCN_LNK_REMOVE, "IMAGE_SCN_LNK_REMOVE"},
+		{peutil.IMAGE_SCN_LNK_COMDAT, "IMAGE_SCN_LNK_COMDAT"},
+		{peutil.IMAGE_SCN_GPREL, "IMAGE_SCN_GPREL"},
+		{peutil.IMAGE_SCN_MEM_PURGEABLE, "IMAGE_SCN_MEM_PURGEABLE"},
+		{peutil.IMAGE_SCN_MEM_16BIT, "IMAGE_SCN_MEM_16BIT"},
+		{peutil.IMAGE_SCN_MEM_LOCKED, "IMAGE_SCN_MEM_LOCKED"},
+		{peutil.IMAGE_SCN_MEM_PRELOAD, "IMAGE_SCN_MEM_PRELOAD"},
+		{peutil.IMAGE_SCN_LNK_NRELOC_OVFL, "IMAGE_SCN_LNK_NRELOC_OVFL"},
+		{peutil.IMAGE_SCN_MEM_DISCARDABLE, "IMAGE_SCN_MEM_DISCARDABLE"},
+		{peutil.IMAGE_SCN_MEM_NOT_CACHED, "IMAGE_SCN_MEM_NOT_CACHED"},
+		{peutil.IMAGE_SCN_MEM_NOT_PAGED, "IMAGE_SCN_MEM_NOT_PAGED"},
+		{peutil.IMAGE_SCN_MEM_SHARED, "IMAGE_SCN_MEM_SHARED"},
+		{peutil.IMAGE_SCN_MEM_EXECUTE, "IMAGE_SCN_MEM_EXECUTE"},
+		{peutil.IMAGE_SCN_MEM_READ, "IMAGE_SCN_MEM_READ"},
+		{peutil.IMAGE_SCN_MEM_WRITE, "IMAGE_SCN_MEM_WRITE"},
+	}
+
+	// the alignment is a 4 bit field, not a set of flags
+	sectionAligns = []string{
+		"",
+		"IMAGE_SCN_ALIGN_1BYTES",
+		"IMAGE_SCN_ALIGN_2BYTES",
+		"IMAGE_SCN_ALIGN_4BYTES",
+		"IMAGE_SCN_ALIGN_8BYTES",
+		"IMAGE_SCN_ALIGN_16BYTES",
+		"IMAGE_SCN_ALIGN_32BYTES",
+		"IMAGE_SCN_ALIGN_64BYTES",
+		"IMAGE_SCN_ALIGN_128BYTES",
+		"IMAGE_SCN_ALIGN_256BYTES",
+		"IMAGE_SCN_ALIGN_512BYTES",
+		"IMAGE_SCN_ALIGN_1024BYTES",
+		"IMAGE_SCN_ALIGN_2048BYTES",
+		"IMAGE_SCN_ALIGN_4096BYTES",
+		"IMAGE_SCN_ALIGN_8192BYTES",
+		"",
+	}
+
+	dirNames = []string{
+		"IMAGE_DIRECTORY_ENTRY_EXPORT",
+		"IMAGE_DIRECTORY_ENTRY_IMPORT",
+		"IMAGE_DIRECTORY_ENTRY_RESOURCE",
+		"IMAGE_DIRECTORY_ENTRY_EXCEPTION",
+		"IMAGE_DIRECTORY_ENTRY_SECURITY",
+		"IMAGE_DIRECTORY_ENTRY_BASERELOC",
+		"IMAGE_DIRECTORY_ENTRY_DEBUG",
+		"IMAGE_DIRECTORY_ENTRY_ARCHITECTURE",
+		"IMAGE_DIRECTORY_ENTRY_GLOBALPTR",
+		"IMAGE_DIRECTORY_ENTRY_TLS",
+		"IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG",
+		"IMAGE_DIRECTORY_ENTRY_BOUND_IMPORT",
+		"IMAGE_DIRECTORY_ENTRY_IAT",
+		"IMAGE_DIRECTORY_ENTRY_DELAY_IMPORT",
+		"IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR",
+	}
 )
 
 func main() {
@@ -28,6 +133,10 @@ func main() {
 
 	flag.Usage = usage
 	flag.Parse()
+	if flag.NArg() == 3 && flag.Arg(0) == "diff" {
+		diff(flag.Arg(1), flag.Arg(2))
+		return
+	}
 	if flag.NArg() != 1 {
 		usage()
 	}
@@ -35,6 +144,7 @@ func main() {
 		*yflag = true
 		*sflag = true
 		*dflag = true
+		*eflag = true
 	}
 
 	dump(flag.Arg(0))
@@ -42,6 +152,7 @@ func main() {
 
 func usage() {
 	fmt.Fprintln(os.Stderr, "usage: pe-dump [options] file")
+	fmt.Fprintln(os.Stderr, "       pe-dump diff old new")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -61,33 +172,32 @@ func dump(name string) {
 		dumpSectionData(f, *rflag)
 		return
 	}
+	if *xflag != "" {
+		extractResources(f, *xflag)
+		return
+	}
+	if *Fflag != "" {
+		writeFunctionBounds(f, newSymbolTable(f), *Fflag)
+		return
+	}
+	if *oflag != "" {
+		extractOverlay(f, name, *oflag)
+		return
+	}
 
-	var (
-		dllch    uint32
-		imgbase  uint64
-		imgsize  uint64
-		entry    uint64
-		codebase uint64
-		database uint64
-	)
-	switch h := f.OptionalHeader.(type) {
-	case *pe.OptionalHeader32:
-		dllch = uint32(h.DllCharacteristics)
-		imgbase = uint64(h.ImageBase)
-		imgsize = uint64(h.SizeOfImage)
-		entry = uint64(h.AddressOfEntryPoint) + imgbase
-		codebase = uint64(h.BaseOfCode) + imgbase
-		database = uint64(h.BaseOfData) + imgbase
-	case *pe.OptionalHeader64:
-		dllch = uint32(h.DllCharacteristics)
-		imgbase = uint64(h.ImageBase)
-		imgsize = uint64(h.SizeOfImage)
-		entry = uint64(h.AddressOfEntryPoint) + imgbase
-		codebase = uint64(h.BaseOfCode) + imgbase
-	default:
-		imgbase = f.ImageBase
+	if *jflag {
+		dumpJSON(f, name)
+		return
 	}
 
+	oh := getOptionalInfo(f)
+	dllch := oh.dllch
+	imgbase := oh.imgbase
+	imgsize := oh.imgsize
+	entry := oh.entry
+	codebase := oh.codebase
+	database := oh.database
+
 	var entryname, codename, dataname string
 	entrysect, _, entryoff := f.LookupVirtualAddress(entry - imgbase)
 	codesect, _, codeoff := f.LookupVirtualAddress(codebase - imgbase)
@@ -115,22 +225,8 @@ func dump(name string) {
 	fmt.Println()
 
 	if dllch != 0 {
-		chars := []struct {
-			bit uint32
-			str string
-		}{
-			{peutil.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE, "IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE"},
-			{peutil.IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY, "IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY"},
-			{peutil.IMAGE_DLLCHARACTERISTICS_NX_COMPAT, "IMAGE_DLLCHARACTERISTICS_NX_COMPAT"},
-			{peutil.IMAGE_DLLCHARACTERISTICS_NO_ISOLATION, "IMAGE_DLLCHARACTERISTICS_NO_ISOLATION"},
-			{peutil.IMAGE_DLLCHARACTERISTICS_NO_SEH, "IMAGE_DLLCHARACTERISTICS_NO_SEH"},
-			{peutil.IMAGE_DLLCHARACTERISTICS_NO_BIND, "IMAGE_DLLCHARACTERISTICS_NO_BIND"},
-			{peutil.IMAGE_DLLCHARACTERISTICS_WDM_DRIVER, "IMAGE_DLLCHARACTERISTICS_WDM_DRIVER"},
-			{peutil.IMAGE_DLLCHARACTERISTICS_TERMINAL_SERVER_AWARE, "IMAGE_DLLCHARACTERISTICS_TERMINAL_SERVER_AWARE"},
-		}
-
 		fmt.Printf("DLL Characteristics: %#x\n", dllch)
-		for _, c := range chars {
+		for _, c := range dllChars {
 			if dllch&c.bit != 0 {
 				fmt.Println(c.str)
 			}
@@ -139,46 +235,6 @@ func dump(name string) {
 	}
 
 	for _, s := range f.Sections {
-		chars := []struct {
-			bit uint32
-			str string
-		}{
-			{peutil.IMAGE_SCN_TYPE_NO_PAD, "IMAGE_SCN_TYPE_NO_PAD"},
-			{peutil.IMAGE_SCN_CNT_CODE, "IMAGE_SCN_CNT_CODE"},
-			{peutil.IMAGE_SCN_CNT_INITIALIZED_DATA, "IMAGE_SCN_CNT_INITIALIZED_DATA"},
-			{peutil.IMAGE_SCN_CNT_UNINITIALIZED_DATA, "IMAGE_SCN_CNT_UNINITIALIZED_DATA"},
-			{peutil.IMAGE_SCN_LNK_OTHER, "IMAGE_SCN_LNK_OTHER"},
-			{peutil.IMAGE_SCN_LNK_INFO, "IMAGE_SCN_LNK_INFO"},
-			{peutil.IMAGE_SCN_LNK_REMOVE, "IMAGE_SCN_LNK_REMOVE"},
-			{peutil.IMAGE_SCN_LNK_COMDAT, "IMAGE_SCN_LNK_COMDAT"},
-			{peutil.IMAGE_SCN_GPREL, "IMAGE_SCN_GPREL"},
-			{peutil.IMAGE_SCN_MEM_PURGEABLE, "IMAGE_SCN_MEM_PURGEABLE"},
-			{peutil.IMAGE_SCN_MEM_16BIT, "IMAGE_SCN_MEM_16BIT"},
-			{peutil.IMAGE_SCN_MEM_LOCKED, "IMAGE_SCN_MEM_LOCKED"},
-			{peutil.IMAGE_SCN_MEM_PRELOAD, "IMAGE_SCN_MEM_PRELOAD"},
-			{peutil.IMAGE_SCN_ALIGN_1BYTES, "IMAGE_SCN_ALIGN_1BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_2BYTES, "IMAGE_SCN_ALIGN_2BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_8BYTES, "IMAGE_SCN_ALIGN_8BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_16BYTES, "IMAGE_SCN_ALIGN_16BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_32BYTES, "IMAGE_SCN_ALIGN_32BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_64BYTES, "IMAGE_SCN_ALIGN_64BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_128BYTES, "IMAGE_SCN_ALIGN_128BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_256BYTES, "IMAGE_SCN_ALIGN_256BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_512BYTES, "IMAGE_SCN_ALIGN_512BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_1024BYTES, "IMAGE_SCN_ALIGN_1024BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_2048BYTES, "IMAGE_SCN_ALIGN_2048BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_4096BYTES, "IMAGE_SCN_ALIGN_4096BYTES"},
-			{peutil.IMAGE_SCN_ALIGN_8192BYTES, "IMAGE_SCN_ALIGN_8192BYTES"},
-			{peutil.IMAGE_SCN_LNK_NRELOC_OVFL, "IMAGE_SCN_LNK_NRELOC_OVFL"},
-			{peutil.IMAGE_SCN_MEM_DISCARDABLE, "IMAGE_SCN_MEM_DISCARDABLE"},
-			{peutil.IMAGE_SCN_MEM_NOT_CACHED, "IMAGE_SCN_MEM_NOT_CACHED"},
-			{peutil.IMAGE_SCN_MEM_NOT_PAGED, "IMAGE_SCN_MEM_NOT_PAGED"},
-			{peutil.IMAGE_SCN_MEM_SHARED, "IMAGE_SCN_MEM_SHARED"},
-			{peutil.IMAGE_SCN_MEM_EXECUTE, "IMAGE_SCN_MEM_EXECUTE"},
-			{peutil.IMAGE_SCN_MEM_READ, "IMAGE_SCN_MEM_READ"},
-			{peutil.IMAGE_SCN_MEM_WRITE, "IMAGE_SCN_MEM_WRITE"},
-		}
-
 		fmt.Printf("Section %s\n", s.Name)
 		fmt.Printf("Virtual Address          : %#x - %#x\n", imgbase+uint64(s.VirtualAddress), imgbase+uint64(s.VirtualAddress)+uint64(s.VirtualSize))
 		fmt.Printf("Virtual Address (Raw)    : %#x - %#x\n", s.VirtualAddress, s.VirtualAddress+s.VirtualSize)
@@ -190,31 +246,13 @@ func dump(name string) {
 		fmt.Printf("Number of Relocations    : %d\n", s.NumberOfRelocations)
 		fmt.Printf("Number of Line Numbers   : %d\n", s.NumberOfLineNumbers)
 		fmt.Printf("Characteristics          : %#x\n", s.Characteristics)
-		for _, c := range chars {
-			if s.Characteristics&c.bit != 0 {
-				fmt.Println(c.str)
-			}
+		fmt.Printf("Entropy                  : %.4f\n", entropy(s.Data))
+		for _, str := range sectionFlags(s.Characteristics) {
+			fmt.Println(str)
 		}
 		fmt.Println()
 	}
 
-	dirNames := []string{
-		"IMAGE_DIRECTORY_ENTRY_EXPORT",
-		"IMAGE_DIRECTORY_ENTRY_IMPORT",
-		"IMAGE_DIRECTORY_ENTRY_RESOURCE",
-		"IMAGE_DIRECTORY_ENTRY_EXCEPTION",
-		"IMAGE_DIRECTORY_ENTRY_SECURITY",
-		"IMAGE_DIRECTORY_ENTRY_BASERELOC",
-		"IMAGE_DIRECTORY_ENTRY_DEBUG",
-		"IMAGE_DIRECTORY_ENTRY_ARCHITECTURE",
-		"IMAGE_DIRECTORY_ENTRY_GLOBALPTR",
-		"IMAGE_DIRECTORY_ENTRY_TLS",
-		"IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG",
-		"IMAGE_DIRECTORY_ENTRY_BOUND_IMPORT",
-		"IMAGE_DIRECTORY_ENTRY_IAT",
-		"IMAGE_DIRECTORY_ENTRY_DELAY_IMPORT",
-		"IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR",
-	}
 	for i, name := range dirNames {
 		d := f.DataDirectory(i)
 		if d == nil {
@@ -235,6 +273,24 @@ func dump(name string) {
 		fmt.Printf("\n")
 	}
 
+	dumpResources(f)
+	dumpCLR(f)
+
+	syms := newSymbolTable(f)
+	dumpBaseRelocations(f)
+	dumpTLS(f, syms)
+	dumpLoadConfig(f, syms)
+	if *eflag {
+		dumpExceptionData(f, syms)
+	}
+	dumpAuthenticode(f, name)
+
+	if raw, err := os.ReadFile(name); err == nil {
+		dumpDebugDirectory(f, raw)
+		dumpRichHeader(raw)
+		dumpTriage(f, raw)
+	}
+
 	spew.Dump(f.FileHeader)
 	fmt.Println()
 	spew.Dump(f.OptionalHeader)
@@ -264,52 +320,43 @@ func dump(name string) {
 	fmt.Println()
 
 	if f.OptionalHeader != nil {
-		is, err := f.ReadImportTable()
-		nsym := 0
-		for _, d := range is {
-			nsym += len(d.Symbols)
-		}
-		fmt.Printf("Imported Symbols: (%d symbols)\n", nsym)
-		fmt.Println(strings.Repeat("-", 80))
-		if err == nil {
-			for _, d := range is {
-				for _, y := range d.Symbols {
-					var s [4]*peutil.Section
-					var a [4]string
-					s[0], _, _ = f.LookupVirtualAddress(y.DLLNameRVA)
-					s[1], _, _ = f.LookupVirtualAddress(y.NameRVA)
-					s[2], _, _ = f.LookupVirtualAddress(y.OriginalThunkRVA)
-					s[3], _, _ = f.LookupVirtualAddress(y.ThunkRVA)
-					for i := range a {
-						if s[i] != nil {
-							a[i] = s[i].Name
-						}
-					}
-					fmt.Printf("%-16s %-70s %#08x %s %#08x %s %#08x %s %#08x %s",
-						d.DLLName, y.Name, f.ImageBase+y.DLLNameRVA, a[0], f.ImageBase+y.NameRVA, a[1], f.ImageBase+y.OriginalThunkRVA, a[2], f.ImageBase+y.ThunkRVA, a[3])
-					fmt.Println()
-				}
-			}
+		raw, _ := os.ReadFile(name)
+		imps := readImports(f)
+		dumpImports(f, "Imported Symbols", imps)
+		delays := readDelayImports(f)
+		if len(delays) > 0 {
+			dumpImports(f, "Delay Imported Symbols", delays)
 		}
-		fmt.Println()
+		dumpBoundImports(f, raw)
 
-		sym, _ := f.ExportedSymbols()
+		sym := readExports(f)
 		numpad := 1
 		if len(sym) > 0 {
-			numpad = int(math.Log10(float64(len(sym)))) + 1
+			ord := sym[len(sym)-1].Ordinal
+			if ord < 1 {
+				ord = 1
+			}
+			numpad = int(math.Log10(float64(ord))) + 1
 		}
 
 		fmt.Printf("Exported Symbols: (%d symbols)\n", len(sym))
 		fmt.Println(strings.Repeat("-", 80))
-		for i, y := range sym {
-			s, _, _ := f.LookupVirtualAddress(y.NameRVA)
-			a := ""
-			if s != nil {
-				a = s.Name
+		for _, y := range sym {
+			name := y.Name
+			if name == "" {
+				name = "[NONAME]"
 			}
-			fmt.Printf("%*d %-80s %#08x %s\n", numpad, i+1, y.Name, f.ImageBase+y.NameRVA, a)
+			fwd := ""
+			if y.Forwarder != "" {
+				fwd = " -> " + y.Forwarder
+			}
+			fmt.Printf("%*d %-80s %#08x %s%s\n", numpad, y.Ordinal, name, f.ImageBase+uint64(y.RVA), sectionName(f, uint64(y.RVA)), fwd)
 		}
 		fmt.Println()
+
+		fmt.Printf("Imphash: %s\n", imphash(imps))
+		fmt.Printf("Exphash: %s\n", exphash(sym))
+		fmt.Println()
 	}
 
 	if *sflag {
@@ -321,6 +368,323 @@ func dump(name string) {
 	}
 }
 
+type jsonHex uint64
+
+func (x jsonHex) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%#x"`, uint64(x))), nil
+}
+
+type jsonFile struct {
+	Machine                 string              `json:"machine"`
+	MachineID               uint16              `json:"machine_id"`
+	Magic                   string              `json:"magic"`
+	TimeDateStamp           uint32              `json:"time_date_stamp"`
+	Characteristics         uint16              `json:"characteristics"`
+	ImageBase               jsonHex             `json:"image_base"`
+	EntryPoint              jsonHex             `json:"entry_point"`
+	BaseOfCode              jsonHex             `json:"base_of_code"`
+	BaseOfData              jsonHex             `json:"base_of_data"`
+	SizeOfImage             uint64              `json:"size_of_image"`
+	SizeOfHeaders           uint32              `json:"size_of_headers"`
+	CheckSum                uint32              `json:"checksum"`
+	Subsystem               uint16              `json:"subsystem"`
+	FileAlignment           uint64              `json:"file_alignment"`
+	SectionAlignment        uint64              `json:"section_alignment"`
+	PointerToSymbolTable    uint32              `json:"pointer_to_symbol_table"`
+	NumberOfSymbols         uint32              `json:"number_of_symbols"`
+	SizeOfOptionalHeader    uint16              `json:"size_of_optional_header"`
+	DllCharacteristics      uint32              `json:"dll_characteristics"`
+	DllCharacteristicsFlags []string            `json:"dll_characteristics_flags"`
+	Sections                []jsonSection       `json:"sections"`
+	DataDirectories         []jsonDataDirectory `json:"data_directories"`
+	Imports                 []jsonImport        `json:"imports"`
+	Exports                 []jsonExport        `json:"exports"`
+	Imphash                 string              `json:"imphash"`
+	Exphash                 string              `json:"exphash"`
+	Resources               []jsonResource      `json:"resources"`
+	VersionInfo             *versionInfo        `json:"version_info"`
+	CodeView                *codeView           `json:"codeview"`
+	CLR                     *jsonCLR            `json:"clr"`
+	Entropy                 float64             `json:"entropy"`
+	Overlay                 *jsonOverlay        `json:"overlay"`
+	Anomalies               []string            `json:"anomalies"`
+	Symbols                 []jsonSymbol        `json:"symbols"`
+	Strings                 []string            `json:"strings"`
+}
+
+type jsonSection struct {
+	Name                 string   `json:"name"`
+	VirtualAddress       jsonHex  `json:"virtual_address"`
+	RVA                  jsonHex  `json:"rva"`
+	VirtualSize          uint32   `json:"virtual_size"`
+	SizeOfRawData        uint32   `json:"size_of_raw_data"`
+	PointerToRawData     uint32   `json:"pointer_to_raw_data"`
+	PointerToRelocations uint32   `json:"pointer_to_relocations"`
+	PointerToLineNumbers uint32   `json:"pointer_to_line_numbers"`
+	NumberOfRelocations  uint16   `json:"number_of_relocations"`
+	NumberOfLineNumbers  uint16   `json:"number_of_line_numbers"`
+	Characteristics      uint32   `json:"characteristics"`
+	Flags                []string `json:"flags"`
+	Entropy              float64  `json:"entropy"`
+}
+
+type jsonOverlay struct {
+	Offset           jsonHex `json:"offset"`
+	Size             int64   `json:"size"`
+	Entropy          float64 `json:"entropy"`
+	CertificateTable bool    `json:"certificate_table"`
+}
+
+type jsonDataDirectory struct {
+	Name    string  `json:"name"`
+	RVA     jsonHex `json:"rva"`
+	Size    uint32  `json:"size"`
+	Section string  `json:"section"`
+}
+
+type jsonImport struct {
+	DLL              string  `json:"dll"`
+	Name             string  `json:"name"`
+	Ordinal          uint16  `json:"ordinal"`
+	ByOrdinal        bool    `json:"by_ordinal"`
+	Delay            bool    `json:"delay"`
+	DLLNameRVA       jsonHex `json:"dll_name_rva"`
+	NameRVA          jsonHex `json:"name_rva"`
+	OriginalThunkRVA jsonHex `json:"original_thunk_rva"`
+	ThunkRVA         jsonHex `json:"thunk_rva"`
+}
+
+type jsonExport struct {
+	Name      string  `json:"name"`
+	Ordinal   uint32  `json:"ordinal"`
+	RVA       jsonHex `json:"rva"`
+	Forwarder string  `json:"forwarder"`
+}
+
+type jsonResource struct {
+	Type     string  `json:"type"`
+	Name     string  `json:"name"`
+	Lang     string  `json:"lang"`
+	RVA      jsonHex `json:"rva"`
+	Size     uint32  `json:"size"`
+	CodePage uint32  `json:"codepage"`
+}
+
+type jsonCLR struct {
+	RuntimeVersion    string       `json:"runtime_version"`
+	Flags             []string     `json:"flags"`
+	EntryPoint        jsonHex      `json:"entry_point"`
+	EntryPointName    string       `json:"entry_point_name"`
+	MetadataVersion   string       `json:"metadata_version"`
+	Assembly          string       `json:"assembly"`
+	AssemblyRefs      []string     `json:"assembly_refs"`
+	PInvokes          []clrPInvoke `json:"pinvokes"`
+	ManifestResources []string     `json:"manifest_resources"`
+}
+
+type jsonSymbol struct {
+	Name          string  `json:"name"`
+	Value         jsonHex `json:"value"`
+	SectionNumber int16   `json:"section_number"`
+	Type          uint16  `json:"type"`
+	StorageClass  uint8   `json:"storage_class"`
+}
+
+// dumpJSON writes everything the text dump shows in a stable schema,
+// addresses are written as hex strings so they survive tools that
+// convert numbers to floating point
+func dumpJSON(f *peutil.File, name string) {
+	oh := getOptionalInfo(f)
+	j := jsonFile{
+		Machine:                 peutil.MachineType(f.Machine),
+		MachineID:               f.Machine,
+		TimeDateStamp:           f.TimeDateStamp,
+		Characteristics:         f.Characteristics,
+		ImageBase:               jsonHex(oh.imgbase),
+		EntryPoint:              jsonHex(oh.entry),
+		BaseOfCode:              jsonHex(oh.codebase),
+		BaseOfData:              jsonHex(oh.database),
+		SizeOfImage:             oh.imgsize,
+		FileAlignment:           uint64(f.FileAlignment),
+		SectionAlignment:        uint64(f.SectionAlignment),
+		PointerToSymbolTable:    f.PointerToSymbolTable,
+		NumberOfSymbols:         f.NumberOfSymbols,
+		SizeOfOptionalHeader:    f.SizeOfOptionalHeader,
+		DllCharacteristics:      oh.dllch,
+		DllCharacteristicsFlags: dllFlags(oh.dllch),
+		Sections:                []jsonSection{},
+		DataDirectories:         []jsonDataDirectory{},
+		Imports:                 []jsonImport{},
+		Exports:                 []jsonExport{},
+		Resources:               []jsonResource{},
+		Anomalies:               []string{},
+		Symbols:                 []jsonSymbol{},
+		Strings:                 []string{},
+	}
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		j.Magic = "PE32"
+		j.SizeOfHeaders = h.SizeOfHeaders
+		j.CheckSum = h.CheckSum
+		j.Subsystem = h.Subsystem
+	case *pe.OptionalHeader64:
+		j.Magic = "PE32+"
+		j.SizeOfHeaders = h.SizeOfHeaders
+		j.CheckSum = h.CheckSum
+		j.Subsystem = h.Subsystem
+	}
+
+	for _, s := range f.Sections {
+		j.Sections = append(j.Sections, jsonSection{
+			Name:                 s.Name,
+			VirtualAddress:       jsonHex(oh.imgbase + uint64(s.VirtualAddress)),
+			RVA:                  jsonHex(s.VirtualAddress),
+			VirtualSize:          s.VirtualSize,
+			SizeOfRawData:        s.Size,
+			PointerToRawData:     s.Offset,
+			PointerToRelocations: s.PointerToRelocations,
+			PointerToLineNumbers: s.PointerToLineNumbers,
+			NumberOfRelocations:  s.NumberOfRelocations,
+			NumberOfLineNumbers:  s.NumberOfLineNumbers,
+			Characteristics:      s.Characteristics,
+			Flags:                sectionFlags(s.Characteristics),
+			Entropy:              entropy(s.Data),
+		})
+	}
+
+	for i, name := range dirNames {
+		d := f.DataDirectory(i)
+		if d == nil {
+			break
+		}
+		jd := jsonDataDirectory{
+			Name: name,
+			RVA:  jsonHex(d.VirtualAddress),
+			Size: d.Size,
+		}
+		if s, _, _ := f.LookupVirtualAddress(uint64(d.VirtualAddress)); s != nil && d.Size > 0 {
+			jd.Section = s.Name
+		}
+		j.DataDirectories = append(j.DataDirectories, jd)
+	}
+
+	if f.OptionalHeader != nil {
+		imps := readImports(f)
+		delays := readDelayImports(f)
+		for i, y := range append(imps, delays...) {
+			j.Imports = append(j.Imports, jsonImport{
+				DLL:              y.DLL,
+				Name:             y.Name,
+				Ordinal:          y.Ordinal,
+				ByOrdinal:        y.ByOrdinal,
+				Delay:            i >= len(imps),
+				DLLNameRVA:       jsonHex(y.DLLNameRVA),
+				NameRVA:          jsonHex(y.NameRVA),
+				OriginalThunkRVA: jsonHex(y.OriginalThunkRVA),
+				ThunkRVA:         jsonHex(y.ThunkRVA),
+			})
+		}
+
+		exps := readExports(f)
+		for _, y := range exps {
+			j.Exports = append(j.Exports, jsonExport{y.Name, y.Ordinal, jsonHex(y.RVA), y.Forwarder})
+		}
+		j.Imphash = imphash(imps)
+		j.Exphash = exphash(exps)
+	}
+
+	res, _ := readResources(f)
+	for _, r := range res {
+		j.Resources = append(j.Resources, jsonResource{r.TypeName(), r.Name.String(), r.Lang.String(), jsonHex(r.RVA), r.Size, r.CodePage})
+		if r.Type.Name == "" && r.Type.ID == RT_VERSION && j.VersionInfo == nil {
+			j.VersionInfo, _ = parseVersionInfo(r.Data)
+		}
+	}
+
+	if raw, err := os.ReadFile(name); err == nil {
+		j.CodeView = readCodeView(f, raw)
+		j.Entropy = entropy(raw)
+		if o := findOverlay(f, raw); o.size > 0 {
+			j.Overlay = &jsonOverlay{jsonHex(o.offset), o.size, entropy(raw[o.offset:]), o.certs}
+		}
+		j.Anomalies = append(j.Anomalies, findAnomalies(f, raw)...)
+	}
+
+	j.CLR = readJSONCLR(f)
+
+	for _, y := range f.Symbols {
+		j.Symbols = append(j.Symbols, jsonSymbol{
+			Name:          y.Name,
+			Value:         jsonHex(y.Value),
+			SectionNumber: y.SectionNumber,
+			Type:          y.Type,
+			StorageClass:  y.StorageClass,
+		})
+	}
+
+	if *sflag {
+		j.Strings = append(j.Strings, f.FindStrings()...)
+	}
+
+	b, err := json.MarshalIndent(&j, "", "\t")
+	ck(err)
+	fmt.Printf("%s\n", b)
+}
+
+type optionalInfo struct {
+	dllch    uint32
+	imgbase  uint64
+	imgsize  uint64
+	entry    uint64
+	codebase uint64
+	database uint64
+}
+
+func getOptionalInfo(f *peutil.File) optionalInfo {
+	var oh optionalInfo
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		oh.dllch = uint32(h.DllCharacteristics)
+		oh.imgbase = uint64(h.ImageBase)
+		oh.imgsize = uint64(h.SizeOfImage)
+		oh.entry = uint64(h.AddressOfEntryPoint) + oh.imgbase
+		oh.codebase = uint64(h.BaseOfCode) + oh.imgbase
+		oh.database = uint64(h.BaseOfData) + oh.imgbase
+	case *pe.OptionalHeader64:
+		oh.dllch = uint32(h.DllCharacteristics)
+		oh.imgbase = uint64(h.ImageBase)
+		oh.imgsize = uint64(h.SizeOfImage)
+		oh.entry = uint64(h.AddressOfEntryPoint) + oh.imgbase
+		oh.codebase = uint64(h.BaseOfCode) + oh.imgbase
+	default:
+		oh.imgbase = f.ImageBase
+	}
+	return oh
+}
+
+func dllFlags(dllch uint32) []string {
+	r := []string{}
+	for _, c := range dllChars {
+		if dllch&c.bit != 0 {
+			r = append(r, c.str)
+		}
+	}
+	return r
+}
+
+func sectionFlags(ch uint32) []string {
+	r := []string{}
+	for _, c := range sectionChars {
+		if ch&c.bit != 0 {
+			r = append(r, c.str)
+		}
+	}
+	if str := sectionAligns[ch>>20&0xf]; str != "" {
+		r = append(r, str)
+	}
+	return r
+}
+
 func dumpDWARF(f *peutil.File) {
 	dw, err := f.DWARF()
 	if err != nil {
@@ -349,3 +713,3373 @@ func dumpSectionData(f *peutil.File, dir string) {
 		}
 	}
 }
+
+// readRVA returns the section data backing size bytes at rva,
+// a negative size returns everything up to the end of the section
+func readRVA(f *peutil.File, rva uint32, size int) []byte {
+	s, _, off := f.LookupVirtualAddress(uint64(rva))
+	if s == nil || off < 0 || off >= len(s.Data) {
+		return nil
+	}
+	b := s.Data[off:]
+	if size >= 0 && size < len(b) {
+		b = b[:size]
+	}
+	return b
+}
+
+func utf16String(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+var resourceTypes = map[uint32]string{
+	1:  "RT_CURSOR",
+	2:  "RT_BITMAP",
+	3:  "RT_ICON",
+	4:  "RT_MENU",
+	5:  "RT_DIALOG",
+	6:  "RT_STRING",
+	7:  "RT_FONTDIR",
+	8:  "RT_FONT",
+	9:  "RT_ACCELERATOR",
+	10: "RT_RCDATA",
+	11: "RT_MESSAGETABLE",
+	12: "RT_GROUP_CURSOR",
+	14: "RT_GROUP_ICON",
+	16: "RT_VERSION",
+	17: "RT_DLGINCLUDE",
+	19: "RT_PLUGPLAY",
+	20: "RT_VXD",
+	21: "RT_ANICURSOR",
+	22: "RT_ANIICON",
+	23: "RT_HTML",
+	24: "RT_MANIFEST",
+}
+
+const (
+	RT_BITMAP     = 2
+	RT_ICON       = 3
+	RT_GROUP_ICON = 14
+	RT_VERSION    = 16
+	RT_HTML       = 23
+	RT_MANIFEST   = 24
+)
+
+// a resource id is either a number or a name, depending on the high bit of the directory entry
+type resourceID struct {
+	ID   uint32
+	Name string
+}
+
+func (r resourceID) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprint(r.ID)
+}
+
+type resource struct {
+	Type     resourceID
+	Name     resourceID
+	Lang     resourceID
+	RVA      uint32
+	Size     uint32
+	CodePage uint32
+	Data     []byte
+}
+
+func (r *resource) TypeName() string {
+	if r.Type.Name == "" {
+		if s, found := resourceTypes[r.Type.ID]; found {
+			return s
+		}
+	}
+	return r.Type.String()
+}
+
+// readResources walks the type/name/language levels of the resource directory
+func readResources(f *peutil.File) ([]resource, error) {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_RESOURCE)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return nil, nil
+	}
+	b := readRVA(f, d.VirtualAddress, -1)
+	if b == nil {
+		return nil, fmt.Errorf("resource directory %#x is not mapped to a section", d.VirtualAddress)
+	}
+
+	var (
+		res  []resource
+		walk func(off uint32, level int, ids []resourceID) error
+	)
+	seen := make(map[uint32]bool)
+	walk = func(off uint32, level int, ids []resourceID) error {
+		if seen[off] || level > 2 {
+			return fmt.Errorf("resource directory loop at offset %#x", off)
+		}
+		seen[off] = true
+
+		if uint64(off)+16 > uint64(len(b)) {
+			return fmt.Errorf("resource directory at offset %#x is truncated", off)
+		}
+		nnamed := binary.LittleEndian.Uint16(b[off+12:])
+		nids := binary.LittleEndian.Uint16(b[off+14:])
+		for i := uint32(0); i < uint32(nnamed)+uint32(nids); i++ {
+			e := off + 16 + i*8
+			if uint64(e)+8 > uint64(len(b)) {
+				return fmt.Errorf("resource directory entry at offset %#x is truncated", e)
+			}
+			name := binary.LittleEndian.Uint32(b[e:])
+			data := binary.LittleEndian.Uint32(b[e+4:])
+
+			var id resourceID
+			if name&0x80000000 != 0 {
+				noff := name &^ 0x80000000
+				if uint64(noff)+2 <= uint64(len(b)) {
+					n := uint32(binary.LittleEndian.Uint16(b[noff:]))
+					if uint64(noff)+2+uint64(n)*2 <= uint64(len(b)) {
+						id.Name = utf16String(b[noff+2 : noff+2+n*2])
+					}
+				}
+			} else {
+				id.ID = name
+			}
+
+			xids := append(append([]resourceID{}, ids...), id)
+			if data&0x80000000 != 0 {
+				if err := walk(data&^0x80000000, level+1, xids); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if uint64(data)+16 > uint64(len(b)) || len(xids) != 3 {
+				return fmt.Errorf("invalid resource data entry at offset %#x", data)
+			}
+			r := resource{
+				Type:     xids[0],
+				Name:     xids[1],
+				Lang:     xids[2],
+				RVA:      binary.LittleEndian.Uint32(b[data:]),
+				Size:     binary.LittleEndian.Uint32(b[data+4:]),
+				CodePage: binary.LittleEndian.Uint32(b[data+8:]),
+			}
+			r.Data = readRVA(f, r.RVA, int(r.Size))
+			res = append(res, r)
+		}
+		return nil
+	}
+	err := walk(0, 0, nil)
+	return res, err
+}
+
+type versionBlock struct {
+	Key      string
+	Type     uint16
+	Value    []byte
+	Children []versionBlock
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// parseVersionBlock decodes one of the nested length/key/value blocks that make up VS_VERSIONINFO
+func parseVersionBlock(b []byte) (versionBlock, int) {
+	var v versionBlock
+	if len(b) < 6 {
+		return v, 0
+	}
+	length := int(binary.LittleEndian.Uint16(b))
+	vlen := int(binary.LittleEndian.Uint16(b[2:]))
+	v.Type = binary.LittleEndian.Uint16(b[4:])
+	if length < 6 || length > len(b) {
+		return v, 0
+	}
+	b = b[:length]
+
+	pos := 6
+	for ; pos+1 < len(b); pos += 2 {
+		if b[pos] == 0 && b[pos+1] == 0 {
+			break
+		}
+	}
+	v.Key = utf16String(b[6:pos])
+	pos = align4(pos + 2)
+
+	// text values measure their length in words
+	if v.Type == 1 {
+		vlen *= 2
+	}
+	if pos+vlen > len(b) {
+		vlen = max(len(b)-pos, 0)
+	}
+	if pos < len(b) {
+		v.Value = b[pos : pos+vlen]
+	}
+	pos = align4(pos + vlen)
+
+	for pos < len(b) {
+		c, n := parseVersionBlock(b[pos:])
+		if n == 0 {
+			break
+		}
+		v.Children = append(v.Children, c)
+		pos = align4(pos + n)
+	}
+	return v, length
+}
+
+type versionInfo struct {
+	FileVersion    string                       `json:"file_version"`
+	ProductVersion string                       `json:"product_version"`
+	FileFlags      uint32                       `json:"file_flags"`
+	FileOS         uint32                       `json:"file_os"`
+	FileType       uint32                       `json:"file_type"`
+	FileSubtype    uint32                       `json:"file_subtype"`
+	Strings        map[string]map[string]string `json:"strings"`
+	Translations   []string                     `json:"translations"`
+}
+
+func parseVersionInfo(b []byte) (*versionInfo, error) {
+	root, n := parseVersionBlock(b)
+	if n == 0 || root.Key != "VS_VERSION_INFO" {
+		return nil, fmt.Errorf("invalid VS_VERSIONINFO")
+	}
+
+	v := &versionInfo{
+		Strings:      make(map[string]map[string]string),
+		Translations: []string{},
+	}
+	if p := root.Value; len(p) >= 52 && binary.LittleEndian.Uint32(p) == 0xfeef04bd {
+		u32 := func(i int) uint32 { return binary.LittleEndian.Uint32(p[i:]) }
+		v.FileVersion = fmt.Sprintf("%d.%d.%d.%d", u32(8)>>16, u32(8)&0xffff, u32(12)>>16, u32(12)&0xffff)
+		v.ProductVersion = fmt.Sprintf("%d.%d.%d.%d", u32(16)>>16, u32(16)&0xffff, u32(20)>>16, u32(20)&0xffff)
+		v.FileFlags = u32(24) & u32(28)
+		v.FileOS = u32(32)
+		v.FileType = u32(36)
+		v.FileSubtype = u32(40)
+	}
+
+	for _, c := range root.Children {
+		switch c.Key {
+		case "StringFileInfo":
+			for _, t := range c.Children {
+				m := make(map[string]string)
+				for _, s := range t.Children {
+					m[s.Key] = strings.TrimRight(utf16String(s.Value), "\x00")
+				}
+				v.Strings[t.Key] = m
+			}
+		case "VarFileInfo":
+			for _, t := range c.Children {
+				for p := t.Value; len(p) >= 4; p = p[4:] {
+					v.Translations = append(v.Translations, fmt.Sprintf("%04x%04x", binary.LittleEndian.Uint16(p), binary.LittleEndian.Uint16(p[2:])))
+				}
+			}
+		}
+	}
+	return v, nil
+}
+
+func dumpResources(f *peutil.File) {
+	res, err := readResources(f)
+	if err != nil {
+		fmt.Printf("Failed to read resources: %v\n\n", err)
+	}
+	if len(res) == 0 {
+		return
+	}
+
+	fmt.Printf("Resources: (%d entries)\n", len(res))
+	fmt.Println(strings.Repeat("-", 80))
+	for _, r := range res {
+		fmt.Printf("%-16s %-24s %-6s %#08x %#08x %d\n", r.TypeName(), r.Name, r.Lang, f.ImageBase+uint64(r.RVA), r.Size, r.CodePage)
+	}
+	fmt.Println()
+
+	for _, r := range res {
+		if r.Type.Name != "" {
+			continue
+		}
+		switch r.Type.ID {
+		case RT_VERSION:
+			v, err := parseVersionInfo(r.Data)
+			if err != nil {
+				fmt.Printf("Version Info %s/%s: %v\n\n", r.Name, r.Lang, err)
+				continue
+			}
+			fmt.Printf("Version Info %s/%s:\n", r.Name, r.Lang)
+			fmt.Printf("File Version    : %s\n", v.FileVersion)
+			fmt.Printf("Product Version : %s\n", v.ProductVersion)
+			fmt.Printf("File Flags      : %#x\n", v.FileFlags)
+			fmt.Printf("File OS         : %#x\n", v.FileOS)
+			fmt.Printf("File Type       : %#x (subtype %#x)\n", v.FileType, v.FileSubtype)
+			for _, t := range sortedKeys(v.Strings) {
+				fmt.Printf("String Table %s:\n", t)
+				for _, k := range sortedKeys(v.Strings[t]) {
+					fmt.Printf("    %-20s: %s\n", k, v.Strings[t][k])
+				}
+			}
+			if len(v.Translations) > 0 {
+				fmt.Printf("Translations    : %s\n", strings.Join(v.Translations, " "))
+			}
+			fmt.Println()
+
+		case RT_MANIFEST:
+			fmt.Printf("Manifest %s/%s:\n", r.Name, r.Lang)
+			fmt.Println(strings.TrimSpace(strings.TrimPrefix(string(r.Data), "\ufeff")))
+			fmt.Println()
+		}
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	var r []string
+	for k := range m {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// extractResources writes every resource to dir, icons and bitmaps
+// get the file headers that are stripped when they are linked in
+func extractResources(f *peutil.File, dir string) {
+	res, err := readResources(f)
+	ck(err)
+
+	os.MkdirAll(dir, 0755)
+	// icon ids are only unique within a language
+	icons := make(map[[2]uint32][]byte)
+	for _, r := range res {
+		if r.Type.Name == "" && r.Type.ID == RT_ICON {
+			icons[[2]uint32{r.Name.ID, r.Lang.ID}] = r.Data
+		}
+	}
+
+	for _, r := range res {
+		ext := ".bin"
+		data := r.Data
+		if r.Type.Name == "" {
+			switch r.Type.ID {
+			case RT_BITMAP:
+				ext = ".bmp"
+				data = makeBitmap(r.Data)
+			case RT_ICON:
+				ext = ".ico"
+				data = makeIcon(r.Data)
+			case RT_GROUP_ICON:
+				ext = ".ico"
+				data = makeGroupIcon(r.Data, icons, r.Lang.ID)
+			case RT_MANIFEST:
+				ext = ".xml"
+			case RT_HTML:
+				ext = ".html"
+			}
+		}
+		if data == nil {
+			fmt.Printf("Skipping malformed resource %s/%s/%s\n", r.TypeName(), r.Name, r.Lang)
+			continue
+		}
+
+		name := fmt.Sprintf("%s_%s_%s%s", r.TypeName(), r.Name, r.Lang, ext)
+		name = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`/\:*?"<>|`, r) {
+				return '_'
+			}
+			return r
+		}, name)
+		name = filepath.Join(dir, name)
+
+		err := os.WriteFile(name, data, 0644)
+		if err == nil {
+			fmt.Println("Wrote out resource", name)
+		} else {
+			fmt.Println(name, err)
+		}
+	}
+}
+
+// makeBitmap prepends the BITMAPFILEHEADER to a DIB
+func makeBitmap(dib []byte) []byte {
+	if len(dib) < 40 {
+		return nil
+	}
+	hdrsize := binary.LittleEndian.Uint32(dib)
+	bitcount := binary.LittleEndian.Uint16(dib[14:])
+	compression := binary.LittleEndian.Uint32(dib[16:])
+	clrused := binary.LittleEndian.Uint32(dib[32:])
+
+	palette := clrused
+	if palette == 0 && bitcount <= 8 {
+		palette = 1 << bitcount
+	}
+	off := 14 + hdrsize + palette*4
+	if compression == 3 && hdrsize == 40 {
+		off += 12
+	}
+
+	b := make([]byte, 14, 14+len(dib))
+	copy(b, "BM")
+	binary.LittleEndian.PutUint32(b[2:], uint32(14+len(dib)))
+	binary.LittleEndian.PutUint32(b[10:], off)
+	return append(b, dib...)
+}
+
+// makeIcon wraps a single RT_ICON image in an ICONDIR
+func makeIcon(img []byte) []byte {
+	var w, h byte
+	var bitcount uint16
+	if len(img) >= 40 && binary.LittleEndian.Uint32(img) == 40 {
+		w = byte(binary.LittleEndian.Uint32(img[4:]))
+		h = byte(binary.LittleEndian.Uint32(img[8:]) / 2)
+		bitcount = binary.LittleEndian.Uint16(img[14:])
+	}
+
+	b := make([]byte, 22, 22+len(img))
+	binary.LittleEndian.PutUint16(b[2:], 1)
+	binary.LittleEndian.PutUint16(b[4:], 1)
+	b[6] = w
+	b[7] = h
+	binary.LittleEndian.PutUint16(b[10:], 1)
+	binary.LittleEndian.PutUint16(b[12:], bitcount)
+	binary.LittleEndian.PutUint32(b[14:], uint32(len(img)))
+	binary.LittleEndian.PutUint32(b[18:], 22)
+	return append(b, img...)
+}
+
+// makeGroupIcon converts a GRPICONDIR into an ICONDIR by replacing
+// the RT_ICON ids of the same language with the file offsets of the images
+func makeGroupIcon(grp []byte, icons map[[2]uint32][]byte, lang uint32) []byte {
+	if len(grp) < 6 {
+		return nil
+	}
+	n := int(binary.LittleEndian.Uint16(grp[4:]))
+	if len(grp) < 6+n*14 {
+		return nil
+	}
+
+	hdr := make([]byte, 6+n*16)
+	copy(hdr, grp[:6])
+	var imgs []byte
+	for i := 0; i < n; i++ {
+		e := grp[6+i*14:]
+		id := uint32(binary.LittleEndian.Uint16(e[12:]))
+		img := icons[[2]uint32{id, lang}]
+
+		d := hdr[6+i*16:]
+		copy(d, e[:8])
+		binary.LittleEndian.PutUint32(d[8:], uint32(len(img)))
+		binary.LittleEndian.PutUint32(d[12:], uint32(len(hdr)+len(imgs)))
+		imgs = append(imgs, img...)
+	}
+	return append(hdr, imgs...)
+}
+
+// symbolTable resolves rvas to the nearest preceding coff or exported symbol
+type symbolTable struct {
+	rvas  []uint32
+	names []string
+}
+
+func newSymbolTable(f *peutil.File) *symbolTable {
+	type sym struct {
+		rva  uint32
+		name string
+	}
+	var p []sym
+	for _, y := range f.Symbols {
+		if y.SectionNumber <= 0 || int(y.SectionNumber) > len(f.Sections) || y.Name == "" {
+			continue
+		}
+		p = append(p, sym{f.Sections[y.SectionNumber-1].VirtualAddress + y.Value, y.Name})
+	}
+	for _, y := range readExports(f) {
+		if y.Name != "" && y.Forwarder == "" {
+			p = append(p, sym{y.RVA, y.Name})
+		}
+	}
+	sort.SliceStable(p, func(i, j int) bool {
+		return p[i].rva < p[j].rva
+	})
+
+	t := &symbolTable{}
+	for _, y := range p {
+		t.rvas = append(t.rvas, y.rva)
+		t.names = append(t.names, y.name)
+	}
+	return t
+}
+
+func (t *symbolTable) lookup(rva uint32) string {
+	i := sort.Search(len(t.rvas), func(i int) bool {
+		return t.rvas[i] > rva
+	}) - 1
+	if i < 0 {
+		return ""
+	}
+	if t.rvas[i] == rva {
+		return t.names[i]
+	}
+	return fmt.Sprintf("%s+%#x", t.names[i], rva-t.rvas[i])
+}
+
+func pointerSize(f *peutil.File) int {
+	if _, ok := f.OptionalHeader.(*pe.OptionalHeader64); ok {
+		return 8
+	}
+	return 4
+}
+
+func readPointer(b []byte, size int) uint64 {
+	if size == 8 {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return uint64(binary.LittleEndian.Uint32(b))
+}
+
+func sectionName(f *peutil.File, rva uint64) string {
+	s, _, _ := f.LookupVirtualAddress(rva)
+	if s == nil {
+		return ""
+	}
+	return s.Name
+}
+
+var relocTypes = []string{
+	"ABSOLUTE",
+	"HIGH",
+	"LOW",
+	"HIGHLOW",
+	"HIGHADJ",
+	"MACHINE_SPECIFIC_5",
+	"RESERVED",
+	"MACHINE_SPECIFIC_7",
+	"MACHINE_SPECIFIC_8",
+	"MACHINE_SPECIFIC_9",
+	"DIR64",
+}
+
+func relocTypeName(typ int) string {
+	if typ < len(relocTypes) {
+		return relocTypes[typ]
+	}
+	return fmt.Sprintf("TYPE_%d", typ)
+}
+
+func dumpBaseRelocations(f *peutil.File) {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_BASERELOC)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return
+	}
+	b := readRVA(f, d.VirtualAddress, int(d.Size))
+
+	var blocks, total int
+	fmt.Println("Base Relocations:")
+	fmt.Println(strings.Repeat("-", 80))
+	for len(b) >= 8 {
+		page := binary.LittleEndian.Uint32(b)
+		size := binary.LittleEndian.Uint32(b[4:])
+		if size < 8 || int(size) > len(b) {
+			fmt.Printf("Invalid relocation block size %#x at page %#x\n", size, page)
+			break
+		}
+
+		var counts [16]int
+		entries := b[8:size]
+		for p := entries; len(p) >= 2; p = p[2:] {
+			counts[binary.LittleEndian.Uint16(p)>>12]++
+		}
+
+		var summary []string
+		for typ, n := range counts {
+			if n > 0 {
+				summary = append(summary, fmt.Sprintf("%s %d", relocTypeName(typ), n))
+			}
+		}
+		fmt.Printf("Page %#08x %-8s %4d entries: %s\n", f.ImageBase+uint64(page), sectionName(f, uint64(page)), len(entries)/2, strings.Join(summary, ", "))
+
+		if *vflag {
+			for p := entries; len(p) >= 2; p = p[2:] {
+				e := binary.LittleEndian.Uint16(p)
+				typ := int(e >> 12)
+				if typ == 0 {
+					continue
+				}
+				fmt.Printf("    %#08x %s\n", f.ImageBase+uint64(page)+uint64(e&0xfff), relocTypeName(typ))
+			}
+		}
+
+		blocks++
+		total += len(entries) / 2
+		b = b[size:]
+	}
+	fmt.Printf("%d blocks, %d entries\n\n", blocks, total)
+}
+
+func dumpTLS(f *peutil.File, syms *symbolTable) {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_TLS)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return
+	}
+
+	ps := pointerSize(f)
+	b := readRVA(f, d.VirtualAddress, -1)
+	if len(b) < 4*ps+8 {
+		fmt.Printf("TLS directory is truncated\n\n")
+		return
+	}
+
+	start := readPointer(b, ps)
+	end := readPointer(b[ps:], ps)
+	index := readPointer(b[2*ps:], ps)
+	callbacks := readPointer(b[3*ps:], ps)
+	zerofill := binary.LittleEndian.Uint32(b[4*ps:])
+	chars := binary.LittleEndian.Uint32(b[4*ps+4:])
+
+	fmt.Println("TLS Directory:")
+	fmt.Printf("Start Address of Raw Data : %#x (%s)\n", start, sectionName(f, start-f.ImageBase))
+	fmt.Printf("End Address of Raw Data   : %#x (%d bytes)\n", end, end-start)
+	fmt.Printf("Address of Index          : %#x (%s)\n", index, sectionName(f, index-f.ImageBase))
+	fmt.Printf("Address of Callbacks      : %#x (%s)\n", callbacks, sectionName(f, callbacks-f.ImageBase))
+	fmt.Printf("Size of Zero Fill         : %#x (%d bytes)\n", zerofill, zerofill)
+	fmt.Printf("Characteristics           : %#x\n", chars)
+
+	if callbacks != 0 {
+		fmt.Println("Callbacks:")
+		p := readRVA(f, uint32(callbacks-f.ImageBase), -1)
+		for ; len(p) >= ps; p = p[ps:] {
+			va := readPointer(p, ps)
+			if va == 0 {
+				break
+			}
+			rva := uint32(va - f.ImageBase)
+			fmt.Printf("    %#016x %-8s %s\n", va, sectionName(f, uint64(rva)), syms.lookup(rva))
+		}
+	}
+	fmt.Println()
+}
+
+var guardFlags = []struct {
+	bit uint32
+	str string
+}{
+	{0x100, "IMAGE_GUARD_CF_INSTRUMENTED"},
+	{0x200, "IMAGE_GUARD_CFW_INSTRUMENTED"},
+	{0x400, "IMAGE_GUARD_CF_FUNCTION_TABLE_PRESENT"},
+	{0x800, "IMAGE_GUARD_SECURITY_COOKIE_UNUSED"},
+	{0x1000, "IMAGE_GUARD_PROTECT_DELAYLOAD_IAT"},
+	{0x2000, "IMAGE_GUARD_DELAYLOAD_IAT_IN_ITS_OWN_SECTION"},
+	{0x4000, "IMAGE_GUARD_CF_EXPORT_SUPPRESSION_INFO_PRESENT"},
+	{0x8000, "IMAGE_GUARD_CF_ENABLE_EXPORT_SUPPRESSION"},
+	{0x10000, "IMAGE_GUARD_CF_LONGJUMP_TABLE_PRESENT"},
+	{0x20000, "IMAGE_GUARD_RF_INSTRUMENTED"},
+	{0x40000, "IMAGE_GUARD_RF_ENABLE"},
+	{0x80000, "IMAGE_GUARD_RF_STRICT"},
+	{0x100000, "IMAGE_GUARD_RETPOLINE_PRESENT"},
+	{0x400000, "IMAGE_GUARD_EH_CONTINUATION_TABLE_PRESENT"},
+	{0x800000, "IMAGE_GUARD_XFG_ENABLED"},
+	{0x1000000, "IMAGE_GUARD_CASTGUARD_PRESENT"},
+	{0x2000000, "IMAGE_GUARD_MEMCPY_PRESENT"},
+}
+
+// loadConfigFields lists the IMAGE_LOAD_CONFIG_DIRECTORY members in order,
+// a size of 0 is a pointer sized member
+func loadConfigFields(ps int) []struct {
+	name string
+	size int
+} {
+	heap := []struct {
+		name string
+		size int
+	}{
+		{"ProcessAffinityMask", 0},
+		{"ProcessHeapFlags", 4},
+	}
+	// the 32-bit structure has the heap flags before the affinity mask
+	if ps == 4 {
+		heap[0], heap[1] = heap[1], heap[0]
+	}
+
+	fields := []struct {
+		name string
+		size int
+	}{
+		{"Size", 4},
+		{"TimeDateStamp", 4},
+		{"MajorVersion", 2},
+		{"MinorVersion", 2},
+		{"GlobalFlagsClear", 4},
+		{"GlobalFlagsSet", 4},
+		{"CriticalSectionDefaultTimeout", 4},
+		{"DeCommitFreeBlockThreshold", 0},
+		{"DeCommitTotalFreeThreshold", 0},
+		{"LockPrefixTable", 0},
+		{"MaximumAllocationSize", 0},
+		{"VirtualMemoryThreshold", 0},
+		heap[0],
+		heap[1],
+		{"CSDVersion", 2},
+		{"DependentLoadFlags", 2},
+		{"EditList", 0},
+		{"SecurityCookie", 0},
+		{"SEHandlerTable", 0},
+		{"SEHandlerCount", 0},
+		{"GuardCFCheckFunctionPointer", 0},
+		{"GuardCFDispatchFunctionPointer", 0},
+		{"GuardCFFunctionTable", 0},
+		{"GuardCFFunctionCount", 0},
+		{"GuardFlags", 4},
+		{"CodeIntegrity", 12},
+		{"GuardAddressTakenIatEntryTable", 0},
+		{"GuardAddressTakenIatEntryCount", 0},
+		{"GuardLongJumpTargetTable", 0},
+		{"GuardLongJumpTargetCount", 0},
+		{"DynamicValueRelocTable", 0},
+		{"CHPEMetadataPointer", 0},
+		{"GuardRFFailureRoutine", 0},
+		{"GuardRFFailureRoutineFunctionPointer", 0},
+		{"DynamicValueRelocTableOffset", 4},
+		{"DynamicValueRelocTableSection", 2},
+		{"Reserved2", 2},
+		{"GuardRFVerifyStackPointerFunctionPointer", 0},
+		{"HotPatchTableOffset", 4},
+		{"Reserved3", 4},
+		{"EnclaveConfigurationPointer", 0},
+		{"VolatileMetadataPointer", 0},
+		{"GuardEHContinuationTable", 0},
+		{"GuardEHContinuationCount", 0},
+		{"GuardXFGCheckFunctionPointer", 0},
+		{"GuardXFGDispatchFunctionPointer", 0},
+		{"GuardXFGTableDispatchFunctionPointer", 0},
+		{"CastGuardOsDeterminedFailureMode", 0},
+		{"GuardMemcpyFunctionPointer", 0},
+	}
+	for i := range fields {
+		if fields[i].size == 0 {
+			fields[i].size = ps
+		}
+	}
+	return fields
+}
+
+// readLoadConfig returns the load config members present in the image,
+// the structure has grown over time and Size says how much of it is there
+func readLoadConfig(f *peutil.File) ([]string, map[string]uint64) {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return nil, nil
+	}
+	b := readRVA(f, d.VirtualAddress, -1)
+	if len(b) < 4 {
+		return nil, nil
+	}
+	size := int(binary.LittleEndian.Uint32(b))
+	if size < len(b) {
+		b = b[:size]
+	}
+
+	var names []string
+	vals := make(map[string]uint64)
+	off := 0
+	for _, fl := range loadConfigFields(pointerSize(f)) {
+		if off+fl.size > len(b) {
+			break
+		}
+		switch fl.size {
+		case 2:
+			vals[fl.name] = uint64(binary.LittleEndian.Uint16(b[off:]))
+		case 4:
+			vals[fl.name] = uint64(binary.LittleEndian.Uint32(b[off:]))
+		case 8:
+			vals[fl.name] = binary.LittleEndian.Uint64(b[off:])
+		}
+		if fl.size <= 8 {
+			names = append(names, fl.name)
+		}
+		off += fl.size
+	}
+	return names, vals
+}
+
+func dumpLoadConfig(f *peutil.File, syms *symbolTable) {
+	names, lc := readLoadConfig(f)
+	if names == nil {
+		return
+	}
+
+	fmt.Println("Load Configuration:")
+	for _, name := range names {
+		fmt.Printf("%-40s : %#x\n", name, lc[name])
+	}
+	fmt.Println()
+
+	if va := lc["SecurityCookie"]; va != 0 {
+		p := readRVA(f, uint32(va-f.ImageBase), pointerSize(f))
+		fmt.Printf("Security Cookie: %#x (%s)", va, sectionName(f, va-f.ImageBase))
+		if len(p) == pointerSize(f) {
+			fmt.Printf(" = %#x", readPointer(p, len(p)))
+		}
+		fmt.Printf("\n\n")
+	}
+
+	if gf := uint32(lc["GuardFlags"]); gf != 0 {
+		fmt.Printf("Guard Flags: %#x\n", gf)
+		for _, c := range guardFlags {
+			if gf&c.bit != 0 {
+				fmt.Println(c.str)
+			}
+		}
+		fmt.Printf("Function Table Stride: %d\n\n", gf>>28)
+	}
+
+	if va, n := lc["SEHandlerTable"], lc["SEHandlerCount"]; va != 0 {
+		fmt.Printf("Safe Exception Handlers: (%d handlers)\n", n)
+		p := readRVA(f, uint32(va-f.ImageBase), -1)
+		for i := uint64(0); i < n && len(p) >= 4; i, p = i+1, p[4:] {
+			rva := binary.LittleEndian.Uint32(p)
+			fmt.Printf("    %#016x %-8s %s\n", f.ImageBase+uint64(rva), sectionName(f, uint64(rva)), syms.lookup(rva))
+		}
+		fmt.Println()
+	}
+
+	tables := []struct {
+		name  string
+		table string
+		count string
+	}{
+		{"CFG Function Table", "GuardCFFunctionTable", "GuardCFFunctionCount"},
+		{"CFG Address Taken IAT Table", "GuardAddressTakenIatEntryTable", "GuardAddressTakenIatEntryCount"},
+		{"CFG Long Jump Target Table", "GuardLongJumpTargetTable", "GuardLongJumpTargetCount"},
+		{"EH Continuation Table", "GuardEHContinuationTable", "GuardEHContinuationCount"},
+	}
+	// each entry is an rva followed by a number of flag bytes given by the stride
+	stride := 4 + int(lc["GuardFlags"]>>28)
+	for _, t := range tables {
+		va, n := lc[t.table], lc[t.count]
+		if va == 0 {
+			continue
+		}
+		fmt.Printf("%s: (%d entries)\n", t.name, n)
+		if *vflag {
+			p := readRVA(f, uint32(va-f.ImageBase), -1)
+			for i := uint64(0); i < n && len(p) >= stride; i, p = i+1, p[stride:] {
+				rva := binary.LittleEndian.Uint32(p)
+				fmt.Printf("    %#016x %-8s %s\n", f.ImageBase+uint64(rva), sectionName(f, uint64(rva)), syms.lookup(rva))
+			}
+		}
+		fmt.Println()
+	}
+}
+
+type runtimeFunction struct {
+	Begin  uint32
+	End    uint32
+	Unwind uint32
+}
+
+// readRuntimeFunctions reads the .pdata entries, arm64 entries only carry the
+// function length so the end is computed from the packed or unpacked unwind data
+func readRuntimeFunctions(f *peutil.File) []runtimeFunction {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_EXCEPTION)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return nil
+	}
+	b := readRVA(f, d.VirtualAddress, int(d.Size))
+
+	var r []runtimeFunction
+	switch f.Machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		for ; len(b) >= 12; b = b[12:] {
+			r = append(r, runtimeFunction{
+				Begin:  binary.LittleEndian.Uint32(b),
+				End:    binary.LittleEndian.Uint32(b[4:]),
+				Unwind: binary.LittleEndian.Uint32(b[8:]),
+			})
+		}
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		for ; len(b) >= 8; b = b[8:] {
+			rf := runtimeFunction{
+				Begin:  binary.LittleEndian.Uint32(b),
+				Unwind: binary.LittleEndian.Uint32(b[4:]),
+			}
+			if rf.Unwind&3 != 0 {
+				rf.End = rf.Begin + (rf.Unwind>>2&0x7ff)*4
+			} else if x := readRVA(f, rf.Unwind, 4); len(x) == 4 {
+				rf.End = rf.Begin + (binary.LittleEndian.Uint32(x)&0x3ffff)*4
+			}
+			r = append(r, rf)
+		}
+	}
+	return r
+}
+
+var unwindRegs = []string{
+	"rax", "rcx", "rdx", "rbx", "rsp", "rbp", "rsi", "rdi",
+	"r8", "r9", "r10", "r11", "r12", "r13", "r14", "r15",
+}
+
+const (
+	UNW_FLAG_EHANDLER  = 1
+	UNW_FLAG_UHANDLER  = 2
+	UNW_FLAG_CHAININFO = 4
+)
+
+// dumpUnwindInfo decodes an x64 UNWIND_INFO and follows the chain of parent entries
+func dumpUnwindInfo(f *peutil.File, syms *symbolTable, rva uint32, depth int) {
+	b := readRVA(f, rva, -1)
+	if len(b) < 4 {
+		fmt.Printf("    invalid unwind info at %#x\n", rva)
+		return
+	}
+
+	version := b[0] & 7
+	uflags := b[0] >> 3
+	prolog := b[1]
+	ncodes := int(b[2])
+	framereg := b[3] & 0xf
+	frameoff := int(b[3]>>4) * 16
+
+	var fl []string
+	if uflags&UNW_FLAG_EHANDLER != 0 {
+		fl = append(fl, "EHANDLER")
+	}
+	if uflags&UNW_FLAG_UHANDLER != 0 {
+		fl = append(fl, "UHANDLER")
+	}
+	if uflags&UNW_FLAG_CHAININFO != 0 {
+		fl = append(fl, "CHAININFO")
+	}
+	frame := "none"
+	if framereg != 0 {
+		frame = fmt.Sprintf("%s+%#x", unwindRegs[framereg], frameoff)
+	}
+	fmt.Printf("    version %d flags %#x [%s] prolog %#x codes %d frame %s\n", version, uflags, strings.Join(fl, " "), prolog, ncodes, frame)
+
+	if len(b) < 4+ncodes*2 {
+		fmt.Printf("    unwind codes are truncated\n")
+		return
+	}
+	codes := b[4 : 4+ncodes*2]
+	slot := func(i int) uint32 {
+		if 2*i+2 > len(codes) {
+			return 0
+		}
+		return uint32(binary.LittleEndian.Uint16(codes[2*i:]))
+	}
+	epilog := false
+	for i := 0; i < ncodes; {
+		off := codes[2*i]
+		op := codes[2*i+1] & 0xf
+		info := codes[2*i+1] >> 4
+		n := 1
+
+		var desc string
+		switch op {
+		case 0:
+			desc = fmt.Sprintf("PUSH_NONVOL %s", unwindRegs[info])
+		case 1:
+			if info == 0 {
+				desc = fmt.Sprintf("ALLOC_LARGE %#x", slot(i+1)*8)
+				n = 2
+			} else {
+				desc = fmt.Sprintf("ALLOC_LARGE %#x", slot(i+1)|slot(i+2)<<16)
+				n = 3
+			}
+		case 2:
+			desc = fmt.Sprintf("ALLOC_SMALL %#x", int(info)*8+8)
+		case 3:
+			desc = fmt.Sprintf("SET_FPREG %s", frame)
+		case 4:
+			desc = fmt.Sprintf("SAVE_NONVOL %s [rsp+%#x]", unwindRegs[info], slot(i+1)*8)
+			n = 2
+		case 5:
+			desc = fmt.Sprintf("SAVE_NONVOL_FAR %s [rsp+%#x]", unwindRegs[info], slot(i+1)|slot(i+2)<<16)
+			n = 3
+		case 6:
+			// version 1 saved the low half of an xmm register here, version 2
+			// reuses it for single slot epilog descriptors
+			if version < 2 {
+				desc = fmt.Sprintf("SAVE_XMM xmm%d [rsp+%#x]", info, slot(i+1)*8)
+				n = 2
+			} else if !epilog {
+				desc = fmt.Sprintf("EPILOG size %#x", off)
+				epilog = true
+			} else {
+				desc = fmt.Sprintf("EPILOG at end-%#x", int(info)<<8|int(off))
+			}
+		case 7:
+			desc = "SPARE_CODE"
+			n = 3
+		case 8:
+			desc = fmt.Sprintf("SAVE_XMM128 xmm%d [rsp+%#x]", info, slot(i+1)*16)
+			n = 2
+		case 9:
+			desc = fmt.Sprintf("SAVE_XMM128_FAR xmm%d [rsp+%#x]", info, slot(i+1)|slot(i+2)<<16)
+			n = 3
+		case 10:
+			desc = "PUSH_MACHFRAME"
+			if info != 0 {
+				desc += " with error code"
+			}
+		default:
+			desc = fmt.Sprintf("UNKNOWN_%d", op)
+		}
+		fmt.Printf("    %#04x %s\n", off, desc)
+		i += n
+	}
+
+	// the code array is padded to an even count before the trailing data
+	tail := 4 + (ncodes+ncodes&1)*2
+	if uflags&UNW_FLAG_CHAININFO != 0 {
+		if len(b) < tail+12 || depth > 32 {
+			fmt.Printf("    chained entry is truncated\n")
+			return
+		}
+		begin := binary.LittleEndian.Uint32(b[tail:])
+		end := binary.LittleEndian.Uint32(b[tail+4:])
+		unwind := binary.LittleEndian.Uint32(b[tail+8:])
+		fmt.Printf("    chained %#016x-%#016x %s\n", f.ImageBase+uint64(begin), f.ImageBase+uint64(end), syms.lookup(begin))
+		dumpUnwindInfo(f, syms, unwind, depth+1)
+	} else if uflags&(UNW_FLAG_EHANDLER|UNW_FLAG_UHANDLER) != 0 && len(b) >= tail+4 {
+		handler := binary.LittleEndian.Uint32(b[tail:])
+		fmt.Printf("    handler %#016x %s data %#016x\n", f.ImageBase+uint64(handler), syms.lookup(handler), f.ImageBase+uint64(rva)+uint64(tail)+4)
+	}
+}
+
+func dumpExceptionData(f *peutil.File, syms *symbolTable) {
+	rf := readRuntimeFunctions(f)
+	if rf == nil {
+		return
+	}
+
+	fmt.Printf("Exception Data: (%d functions)\n", len(rf))
+	fmt.Println(strings.Repeat("-", 80))
+	for _, r := range rf {
+		fmt.Printf("%#016x-%#016x %#08x %s\n", f.ImageBase+uint64(r.Begin), f.ImageBase+uint64(r.End), r.Unwind, syms.lookup(r.Begin))
+		if f.Machine == pe.IMAGE_FILE_MACHINE_AMD64 {
+			dumpUnwindInfo(f, syms, r.Unwind, 0)
+		}
+	}
+	fmt.Println()
+}
+
+// writeFunctionBounds writes the .pdata function ranges in nm -S format
+func writeFunctionBounds(f *peutil.File, syms *symbolTable, name string) {
+	rf := readRuntimeFunctions(f)
+	if rf == nil {
+		log.Fatal("no exception data")
+	}
+
+	w, err := os.Create(name)
+	ck(err)
+	for _, r := range rf {
+		sym := syms.lookup(r.Begin)
+		if sym == "" || strings.Contains(sym, "+") {
+			sym = fmt.Sprintf("sub_%x", f.ImageBase+uint64(r.Begin))
+		}
+		fmt.Fprintf(w, "%016x %016x T %s\n", f.ImageBase+uint64(r.Begin), r.End-r.Begin, sym)
+	}
+	ck(w.Close())
+}
+
+var (
+	oidSignedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSpcIndirectData  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+	oidMessageDigest    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidCounterSignature = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	oidRFC3161Timestamp = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 3, 3, 1}
+	oidNestedSignature  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 4, 1}
+	oidDigestAlgorithms = map[string]crypto.Hash{
+		"1.2.840.113549.2.5":     crypto.MD5,
+		"1.3.14.3.2.26":          crypto.SHA1,
+		"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+		"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+		"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	}
+)
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue     `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue     `asn1:"optional,tag:1"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	SignerID                  asn1.RawValue
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+type pkcs7IssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type spcIndirectDataContent struct {
+	Data          asn1.RawValue
+	MessageDigest struct {
+		Algorithm pkix.AlgorithmIdentifier
+		Digest    []byte
+	}
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint asn1.RawValue
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+func parseAttributes(raw asn1.RawValue) []pkcs7Attribute {
+	var attrs []pkcs7Attribute
+	for rest := raw.Bytes; len(rest) > 0; {
+		var a pkcs7Attribute
+		var err error
+		rest, err = asn1.Unmarshal(rest, &a)
+		if err != nil {
+			break
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+func findAttribute(attrs []pkcs7Attribute, oid asn1.ObjectIdentifier) []byte {
+	for _, a := range attrs {
+		if a.Type.Equal(oid) {
+			return a.Values.Bytes
+		}
+	}
+	return nil
+}
+
+func parseSignedData(der []byte) (*pkcs7SignedData, []*x509.Certificate, error) {
+	var ci pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, nil, err
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, nil, fmt.Errorf("unexpected content type %v", ci.ContentType)
+	}
+
+	var sd pkcs7SignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, nil, err
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	return &sd, certs, err
+}
+
+// signedContent returns the encapsulated content, pkcs7 stores it directly
+// while cms wraps it in an octet string
+func signedContent(sd *pkcs7SignedData) asn1.RawValue {
+	var c asn1.RawValue
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &c); err != nil {
+		return c
+	}
+	if c.Class == asn1.ClassUniversal && c.Tag == asn1.TagOctetString {
+		var inner asn1.RawValue
+		if _, err := asn1.Unmarshal(c.Bytes, &inner); err == nil {
+			return inner
+		}
+	}
+	return c
+}
+
+func findSigner(si *pkcs7SignerInfo, certs []*x509.Certificate) *x509.Certificate {
+	var ias pkcs7IssuerAndSerial
+	if _, err := asn1.Unmarshal(si.SignerID.FullBytes, &ias); err == nil {
+		for _, c := range certs {
+			if c.SerialNumber.Cmp(ias.Serial) == 0 && bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) {
+				return c
+			}
+		}
+	}
+	// version 3 signers are identified by the subject key identifier
+	if si.SignerID.Class == asn1.ClassContextSpecific && si.SignerID.Tag == 0 {
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, si.SignerID.Bytes) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// verifySigner checks the message digest attribute against the signed content
+// and the signature over the authenticated attributes
+func verifySigner(si *pkcs7SignerInfo, content []byte, signer *x509.Certificate) error {
+	h, found := oidDigestAlgorithms[si.DigestAlgorithm.Algorithm.String()]
+	if !found || !h.Available() {
+		return fmt.Errorf("unsupported digest algorithm %v", si.DigestAlgorithm.Algorithm)
+	}
+	if signer == nil {
+		return fmt.Errorf("signer certificate not found")
+	}
+
+	var signed []byte
+	if len(si.AuthenticatedAttributes.Bytes) > 0 {
+		var md []byte
+		if _, err := asn1.Unmarshal(findAttribute(parseAttributes(si.AuthenticatedAttributes), oidMessageDigest), &md); err != nil {
+			return fmt.Errorf("missing message digest attribute")
+		}
+		d := h.New()
+		d.Write(content)
+		if !bytes.Equal(md, d.Sum(nil)) {
+			return fmt.Errorf("message digest mismatch")
+		}
+
+		// the signature covers the attributes encoded as a SET, not as the implicit [0]
+		signed = append([]byte{}, si.AuthenticatedAttributes.FullBytes...)
+		signed[0] = 0x31
+	} else {
+		signed = content
+	}
+
+	d := h.New()
+	d.Write(signed)
+	sum := d.Sum(nil)
+	switch pub := signer.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, h, sum, si.EncryptedDigest)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, sum, si.EncryptedDigest) {
+			return fmt.Errorf("ecdsa verification failure")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported public key algorithm %v", signer.PublicKeyAlgorithm)
+}
+
+// authenticodeHash hashes the image minus the checksum, the security
+// directory entry and the certificate table
+func authenticodeHash(f *peutil.File, b []byte, h crypto.Hash) ([]byte, error) {
+	if len(b) < 0x40 {
+		return nil, fmt.Errorf("file too small")
+	}
+	opt := int(binary.LittleEndian.Uint32(b[0x3c:])) + 4 + 20
+	checksum := opt + 64
+	secdir := opt + 96 + 4*8
+	if pointerSize(f) == 8 {
+		secdir = opt + 112 + 4*8
+	}
+	if secdir+8 > len(b) {
+		return nil, fmt.Errorf("optional header is truncated")
+	}
+
+	certoff := int(binary.LittleEndian.Uint32(b[secdir:]))
+	certsize := int(binary.LittleEndian.Uint32(b[secdir+4:]))
+	end := len(b)
+	if certsize > 0 {
+		if certoff < secdir+8 || certoff+certsize > len(b) {
+			return nil, fmt.Errorf("certificate table %#x-%#x is outside the file", certoff, certoff+certsize)
+		}
+		end = certoff
+	}
+
+	d := h.New()
+	d.Write(b[:checksum])
+	d.Write(b[checksum+4 : secdir])
+	d.Write(b[secdir+8 : end])
+	if certsize > 0 {
+		d.Write(b[certoff+certsize:])
+	}
+	return d.Sum(nil), nil
+}
+
+func dumpCertificates(certs []*x509.Certificate, indent string) {
+	for i, c := range certs {
+		issuer := "not in signature"
+		for j, p := range certs {
+			if bytes.Equal(c.RawIssuer, p.RawSubject) {
+				issuer = fmt.Sprintf("[%d]", j)
+				if err := c.CheckSignatureFrom(p); err != nil {
+					issuer += " (bad signature: " + err.Error() + ")"
+				}
+				break
+			}
+		}
+		fmt.Printf("%s[%d] Subject    : %s\n", indent, i, c.Subject.String())
+		fmt.Printf("%s    Issuer     : %s\n", indent, c.Issuer.String())
+		fmt.Printf("%s    Serial     : %x\n", indent, c.SerialNumber)
+		fmt.Printf("%s    Validity   : %s - %s\n", indent, c.NotBefore.UTC().Format(time.RFC3339), c.NotAfter.UTC().Format(time.RFC3339))
+		fmt.Printf("%s    Algorithm  : %v %v\n", indent, c.SignatureAlgorithm, c.PublicKeyAlgorithm)
+		fmt.Printf("%s    Issued By  : %s\n", indent, issuer)
+		if c.IsCA {
+			fmt.Printf("%s    CA         : true\n", indent)
+		}
+	}
+}
+
+func dumpTimestamps(si *pkcs7SignerInfo, certs []*x509.Certificate, indent string) {
+	var t time.Time
+	if v := findAttribute(parseAttributes(si.AuthenticatedAttributes), oidSigningTime); v != nil {
+		if _, err := asn1.Unmarshal(v, &t); err == nil {
+			fmt.Printf("%sSigning Time      : %s\n", indent, t.UTC().Format(time.RFC3339))
+		}
+	}
+
+	unauth := parseAttributes(si.UnauthenticatedAttributes)
+	if v := findAttribute(unauth, oidCounterSignature); v != nil {
+		var cs pkcs7SignerInfo
+		if _, err := asn1.Unmarshal(v, &cs); err == nil {
+			if tv := findAttribute(parseAttributes(cs.AuthenticatedAttributes), oidSigningTime); tv != nil {
+				if _, err := asn1.Unmarshal(tv, &t); err == nil {
+					fmt.Printf("%sCounter Signature : %s\n", indent, t.UTC().Format(time.RFC3339))
+				}
+			}
+			status := "VALID"
+			if err := verifySigner(&cs, si.EncryptedDigest, findSigner(&cs, certs)); err != nil {
+				status = "INVALID: " + err.Error()
+			}
+			fmt.Printf("%sCounter Signer    : %s\n", indent, status)
+		}
+	}
+
+	if v := findAttribute(unauth, oidRFC3161Timestamp); v != nil {
+		sd, tcerts, err := parseSignedData(v)
+		if err != nil {
+			fmt.Printf("%sRFC3161 Timestamp : %v\n", indent, err)
+			return
+		}
+		var ti tstInfo
+		content := signedContent(sd)
+		if _, err := asn1.Unmarshal(content.FullBytes, &ti); err != nil {
+			fmt.Printf("%sRFC3161 Timestamp : %v\n", indent, err)
+			return
+		}
+		fmt.Printf("%sRFC3161 Timestamp : %s\n", indent, ti.GenTime.UTC().Format(time.RFC3339))
+		for i := range sd.SignerInfos {
+			ts := &sd.SignerInfos[i]
+			status := "VALID"
+			signer := findSigner(ts, tcerts)
+			if err := verifySigner(ts, content.FullBytes, signer); err != nil {
+				status = "INVALID: " + err.Error()
+			}
+			if signer != nil {
+				fmt.Printf("%sTimestamp Signer  : %s\n", indent, signer.Subject.String())
+			}
+			fmt.Printf("%sTimestamp Status  : %s\n", indent, status)
+		}
+		dumpCertificates(tcerts, indent+"    ")
+	}
+}
+
+func dumpSignature(f *peutil.File, b []byte, der []byte, indent string) {
+	sd, certs, err := parseSignedData(der)
+	if err != nil {
+		fmt.Printf("%sFailed to parse signature: %v\n", indent, err)
+		return
+	}
+
+	content := signedContent(sd)
+	var spc spcIndirectDataContent
+	if !sd.ContentInfo.ContentType.Equal(oidSpcIndirectData) {
+		fmt.Printf("%sUnexpected content type %v\n", indent, sd.ContentInfo.ContentType)
+		return
+	}
+	if _, err := asn1.Unmarshal(content.FullBytes, &spc); err != nil {
+		fmt.Printf("%sFailed to parse SpcIndirectDataContent: %v\n", indent, err)
+		return
+	}
+
+	algo := spc.MessageDigest.Algorithm.Algorithm
+	h, found := oidDigestAlgorithms[algo.String()]
+	fmt.Printf("%sDigest Algorithm  : %v\n", indent, h)
+	fmt.Printf("%sSigned Digest     : %x\n", indent, spc.MessageDigest.Digest)
+	if !found || !h.Available() {
+		fmt.Printf("%sImage Hash        : unsupported digest algorithm %v\n", indent, algo)
+	} else if sum, err := authenticodeHash(f, b, h); err != nil {
+		fmt.Printf("%sImage Hash        : %v\n", indent, err)
+	} else {
+		status := "MISMATCH"
+		if bytes.Equal(sum, spc.MessageDigest.Digest) {
+			status = "MATCH"
+		}
+		fmt.Printf("%sImage Digest      : %x\n", indent, sum)
+		fmt.Printf("%sImage Hash        : %s\n", indent, status)
+	}
+
+	for i := range sd.SignerInfos {
+		si := &sd.SignerInfos[i]
+		signer := findSigner(si, certs)
+
+		// pkcs7 signs the value of the content without its tag and length, cms signs the whole encoding
+		status := "VALID"
+		err := verifySigner(si, content.Bytes, signer)
+		if err != nil && verifySigner(si, content.FullBytes, signer) == nil {
+			err = nil
+		}
+		if err != nil {
+			status = "INVALID: " + err.Error()
+		}
+		if signer != nil {
+			fmt.Printf("%sSigner            : %s\n", indent, signer.Subject.String())
+			fmt.Printf("%sSigner Issuer     : %s\n", indent, signer.Issuer.String())
+		}
+		fmt.Printf("%sSignature         : %s\n", indent, status)
+		dumpTimestamps(si, certs, indent)
+
+		for _, a := range parseAttributes(si.UnauthenticatedAttributes) {
+			if !a.Type.Equal(oidNestedSignature) {
+				continue
+			}
+			for rest := a.Values.Bytes; len(rest) > 0; {
+				var nested asn1.RawValue
+				rest, err = asn1.Unmarshal(rest, &nested)
+				if err != nil {
+					break
+				}
+				fmt.Printf("%sNested Signature:\n", indent)
+				dumpSignature(f, b, nested.FullBytes, indent+"    ")
+			}
+		}
+	}
+
+	fmt.Printf("%sCertificates: (%d certificates)\n", indent, len(certs))
+	dumpCertificates(certs, indent+"    ")
+}
+
+func dumpAuthenticode(f *peutil.File, name string) {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_SECURITY)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		fmt.Printf("Failed to read %v: %v\n\n", name, err)
+		return
+	}
+
+	// the security directory holds a file offset, not an rva
+	off := uint64(d.VirtualAddress)
+	end := off + uint64(d.Size)
+	if end > uint64(len(b)) {
+		fmt.Printf("Certificate table %#x-%#x is outside the file\n\n", off, end)
+		return
+	}
+
+	fmt.Println("Authenticode Signature:")
+	fmt.Println(strings.Repeat("-", 80))
+	for off+8 <= end {
+		length := uint64(binary.LittleEndian.Uint32(b[off:]))
+		revision := binary.LittleEndian.Uint16(b[off+4:])
+		typ := binary.LittleEndian.Uint16(b[off+6:])
+		if length < 8 || off+length > end {
+			fmt.Printf("Invalid certificate entry length %#x at %#x\n", length, off)
+			break
+		}
+
+		fmt.Printf("Certificate Entry : %#x-%#x revision %#x type %#x\n", off, off+length, revision, typ)
+		if typ == 2 {
+			dumpSignature(f, b, b[off+8:off+length], "")
+		}
+		fmt.Println()
+		off = (off + length + 7) &^ 7
+	}
+}
+
+var debugTypes = []string{
+	"UNKNOWN",
+	"COFF",
+	"CODEVIEW",
+	"FPO",
+	"MISC",
+	"EXCEPTION",
+	"FIXUP",
+	"OMAP_TO_SRC",
+	"OMAP_FROM_SRC",
+	"BORLAND",
+	"RESERVED10",
+	"CLSID",
+	"VC_FEATURE",
+	"POGO",
+	"ILTCG",
+	"MPX",
+	"REPRO",
+	"EMBEDDED_PORTABLE_PDB",
+	"SPGO",
+	"PDBCHECKSUM",
+	"EX_DLLCHARACTERISTICS",
+}
+
+const (
+	IMAGE_DEBUG_TYPE_CODEVIEW              = 2
+	IMAGE_DEBUG_TYPE_VC_FEATURE            = 12
+	IMAGE_DEBUG_TYPE_POGO                  = 13
+	IMAGE_DEBUG_TYPE_REPRO                 = 16
+	IMAGE_DEBUG_TYPE_EX_DLLCHARACTERISTICS = 20
+)
+
+type debugDirectory struct {
+	Characteristics  uint32
+	TimeDateStamp    uint32
+	MajorVersion     uint16
+	MinorVersion     uint16
+	Type             uint32
+	SizeOfData       uint32
+	AddressOfRawData uint32
+	PointerToRawData uint32
+	Data             []byte
+}
+
+func debugTypeName(typ uint32) string {
+	if int(typ) < len(debugTypes) {
+		return debugTypes[typ]
+	}
+	return fmt.Sprintf("TYPE_%d", typ)
+}
+
+// readDebugDirectory reads the entries and their data, the data is taken
+// from the file offset since it is not always mapped into a section
+func readDebugDirectory(f *peutil.File, b []byte) []debugDirectory {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_DEBUG)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return nil
+	}
+
+	var r []debugDirectory
+	for p := readRVA(f, d.VirtualAddress, int(d.Size)); len(p) >= 28; p = p[28:] {
+		e := debugDirectory{
+			Characteristics:  binary.LittleEndian.Uint32(p),
+			TimeDateStamp:    binary.LittleEndian.Uint32(p[4:]),
+			MajorVersion:     binary.LittleEndian.Uint16(p[8:]),
+			MinorVersion:     binary.LittleEndian.Uint16(p[10:]),
+			Type:             binary.LittleEndian.Uint32(p[12:]),
+			SizeOfData:       binary.LittleEndian.Uint32(p[16:]),
+			AddressOfRawData: binary.LittleEndian.Uint32(p[20:]),
+			PointerToRawData: binary.LittleEndian.Uint32(p[24:]),
+		}
+		if off, size := uint64(e.PointerToRawData), uint64(e.SizeOfData); off != 0 && off+size <= uint64(len(b)) {
+			e.Data = b[off : off+size]
+		} else if e.AddressOfRawData != 0 {
+			e.Data = readRVA(f, e.AddressOfRawData, int(e.SizeOfData))
+		}
+		r = append(r, e)
+	}
+	return r
+}
+
+type codeView struct {
+	Signature string `json:"signature"`
+	GUID      string `json:"guid"`
+	Age       uint32 `json:"age"`
+	Path      string `json:"path"`
+	Key       string `json:"key"`
+}
+
+// parseCodeView decodes the RSDS (pdb 7.0) and NB10 (pdb 2.0) records
+// along with the key a symbol server stores the pdb under
+func parseCodeView(b []byte) (*codeView, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("codeview record is truncated")
+	}
+
+	cv := &codeView{Signature: string(b[:4])}
+	var id string
+	switch cv.Signature {
+	case "RSDS":
+		if len(b) < 24 {
+			return nil, fmt.Errorf("RSDS record is truncated")
+		}
+		g := b[4:20]
+		cv.GUID = fmt.Sprintf("%08X-%04X-%04X-%X-%X", binary.LittleEndian.Uint32(g), binary.LittleEndian.Uint16(g[4:]), binary.LittleEndian.Uint16(g[6:]), g[8:10], g[10:])
+		cv.Age = binary.LittleEndian.Uint32(b[20:])
+		cv.Path = cstring(b[24:])
+		id = strings.Replace(cv.GUID, "-", "", -1)
+	case "NB10":
+		if len(b) < 16 {
+			return nil, fmt.Errorf("NB10 record is truncated")
+		}
+		cv.GUID = fmt.Sprintf("%08X", binary.LittleEndian.Uint32(b[8:]))
+		cv.Age = binary.LittleEndian.Uint32(b[12:])
+		cv.Path = cstring(b[16:])
+		id = cv.GUID
+	default:
+		return nil, fmt.Errorf("unknown codeview signature %q", cv.Signature)
+	}
+
+	pdb := cv.Path
+	if i := strings.LastIndexAny(pdb, `\/`); i >= 0 {
+		pdb = pdb[i+1:]
+	}
+	cv.Key = fmt.Sprintf("%s/%s%X/%s", pdb, id, cv.Age, pdb)
+	return cv, nil
+}
+
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func readCodeView(f *peutil.File, b []byte) *codeView {
+	for _, e := range readDebugDirectory(f, b) {
+		if e.Type == IMAGE_DEBUG_TYPE_CODEVIEW {
+			if cv, err := parseCodeView(e.Data); err == nil {
+				return cv
+			}
+		}
+	}
+	return nil
+}
+
+func dumpDebugDirectory(f *peutil.File, b []byte) {
+	dd := readDebugDirectory(f, b)
+	if dd == nil {
+		return
+	}
+
+	oh := getOptionalInfo(f)
+	fmt.Printf("Debug Directory: (%d entries)\n", len(dd))
+	fmt.Println(strings.Repeat("-", 80))
+	for _, e := range dd {
+		fmt.Printf("%-22s time %#08x version %d.%d size %#x rva %#x offset %#x\n",
+			debugTypeName(e.Type), e.TimeDateStamp, e.MajorVersion, e.MinorVersion, e.SizeOfData, e.AddressOfRawData, e.PointerToRawData)
+
+		p := e.Data
+		switch e.Type {
+		case IMAGE_DEBUG_TYPE_CODEVIEW:
+			cv, err := parseCodeView(p)
+			if err != nil {
+				fmt.Printf("    %v\n", err)
+				break
+			}
+			fmt.Printf("    Signature  : %s\n", cv.Signature)
+			fmt.Printf("    GUID       : %s\n", cv.GUID)
+			fmt.Printf("    Age        : %d\n", cv.Age)
+			fmt.Printf("    PDB        : %s\n", cv.Path)
+			fmt.Printf("    PDB Key    : %s\n", cv.Key)
+
+		case IMAGE_DEBUG_TYPE_POGO:
+			if len(p) < 4 {
+				break
+			}
+			fmt.Printf("    Signature  : %q\n", string(p[:4]))
+			for p = p[4:]; len(p) >= 8; {
+				rva := binary.LittleEndian.Uint32(p)
+				size := binary.LittleEndian.Uint32(p[4:])
+				name := cstring(p[8:])
+				fmt.Printf("    %#016x %#08x %-8s %s\n", f.ImageBase+uint64(rva), size, sectionName(f, uint64(rva)), name)
+				n := align4(8 + len(name) + 1)
+				if n > len(p) {
+					break
+				}
+				p = p[n:]
+			}
+
+		case IMAGE_DEBUG_TYPE_VC_FEATURE:
+			names := []string{"Pre-VC++ 11.00", "C/C++", "/GS", "/sdl", "guardN"}
+			for i, name := range names {
+				if len(p) >= 4*(i+1) {
+					fmt.Printf("    %-15s: %d\n", name, binary.LittleEndian.Uint32(p[4*i:]))
+				}
+			}
+
+		case IMAGE_DEBUG_TYPE_REPRO:
+			// deterministic builds replace the timestamps with a hash of the output
+			if len(p) >= 4 {
+				n := int(binary.LittleEndian.Uint32(p))
+				if 4+n <= len(p) {
+					fmt.Printf("    Hash       : %x\n", p[4:4+n])
+				}
+			} else {
+				fmt.Printf("    Hash       : %08x\n", e.TimeDateStamp)
+			}
+
+		case IMAGE_DEBUG_TYPE_EX_DLLCHARACTERISTICS:
+			if len(p) >= 4 {
+				fl := binary.LittleEndian.Uint32(p)
+				fmt.Printf("    Flags      : %#x", fl)
+				if fl&1 != 0 {
+					fmt.Printf(" IMAGE_DLLCHARACTERISTICS_EX_CET_COMPAT")
+				}
+				fmt.Println()
+			}
+		}
+	}
+	fmt.Printf("Image Key: %08X%x\n\n", f.TimeDateStamp, oh.imgsize)
+}
+
+var richProducts = []string{
+	"Unknown", "Import0", "Linker510", "Cvtomf510", "Linker600", "Cvtomf600", "Cvtres500", "Utc11_Basic",
+	"Utc11_C", "Utc12_Basic", "Utc12_C", "Utc12_CPP", "AliasObj60", "VisualBasic60", "Masm613", "Masm710",
+	"Linker511", "Cvtomf511", "Masm614", "Linker512", "Cvtomf512", "Utc12_C_Std", "Utc12_CPP_Std", "Utc12_C_Book",
+	"Utc12_CPP_Book", "Implib700", "Cvtomf700", "Utc13_Basic", "Utc13_C", "Utc13_CPP", "Linker610", "Cvtomf610",
+	"Linker601", "Cvtomf601", "Utc12_1_Basic", "Utc12_1_C", "Utc12_1_CPP", "Linker620", "Cvtomf620", "AliasObj70",
+	"Linker621", "Cvtomf621", "Masm615", "Utc13_LTCG_C", "Utc13_LTCG_CPP", "Masm620", "ILAsm100", "Utc12_2_Basic",
+	"Utc12_2_C", "Utc12_2_CPP", "Utc12_2_C_Std", "Utc12_2_CPP_Std", "Utc12_2_C_Book", "Utc12_2_CPP_Book", "Implib622", "Cvtomf622",
+	"Cvtres501", "Utc13_C_Std", "Utc13_CPP_Std", "Cvtpgd1300", "Linker622", "Linker700", "Export622", "Export700",
+	"Masm700", "Utc13_POGO_I_C", "Utc13_POGO_I_CPP", "Utc13_POGO_O_C", "Utc13_POGO_O_CPP", "Cvtres700", "Cvtres710p", "Linker710p",
+	"Cvtomf710p", "Export710p", "Implib710p", "Masm710p", "Utc1310p_C", "Utc1310p_CPP", "Utc1310p_C_Std", "Utc1310p_CPP_Std",
+	"Utc1310p_LTCG_C", "Utc1310p_LTCG_CPP", "Utc1310p_POGO_I_C", "Utc1310p_POGO_I_CPP", "Utc1310p_POGO_O_C", "Utc1310p_POGO_O_CPP", "Linker624", "Cvtomf624",
+	"Export624", "Implib624", "Linker710", "Cvtomf710", "Export710", "Implib710", "Cvtres710", "Utc1310_C",
+	"Utc1310_CPP", "Utc1310_C_Std", "Utc1310_CPP_Std", "Utc1310_LTCG_C", "Utc1310_LTCG_CPP", "Utc1310_POGO_I_C", "Utc1310_POGO_I_CPP", "Utc1310_POGO_O_C",
+	"Utc1310_POGO_O_CPP", "AliasObj710", "AliasObj710p", "Cvtpgd1310", "Cvtpgd1310p", "Utc1400_C", "Utc1400_CPP", "Utc1400_C_Std",
+	"Utc1400_CPP_Std", "Utc1400_LTCG_C", "Utc1400_LTCG_CPP", "Utc1400_POGO_I_C", "Utc1400_POGO_I_CPP", "Utc1400_POGO_O_C", "Utc1400_POGO_O_CPP", "Cvtpgd1400",
+	"Linker800", "Cvtomf800", "Export800", "Implib800", "Cvtres800", "Masm800", "AliasObj800", "PhoenixPrerelease",
+	"Utc1400_CVTCIL_C", "Utc1400_CVTCIL_CPP", "Utc1400_LTCG_MSIL", "Utc1500_C", "Utc1500_CPP", "Utc1500_C_Std", "Utc1500_CPP_Std", "Utc1500_CVTCIL_C",
+	"Utc1500_CVTCIL_CPP", "Utc1500_LTCG_C", "Utc1500_LTCG_CPP", "Utc1500_LTCG_MSIL", "Utc1500_POGO_I_C", "Utc1500_POGO_I_CPP", "Utc1500_POGO_O_C", "Utc1500_POGO_O_CPP",
+	"Cvtpgd1500", "Linker900", "Export900", "Implib900", "Cvtres900", "Masm900", "AliasObj900", "Resource",
+	"AliasObj1000", "Cvtpgd1600", "Cvtres1000", "Export1000", "Implib1000", "Linker1000", "Masm1000", "Phx1600_C",
+	"Phx1600_CPP", "Phx1600_CVTCIL_C", "Phx1600_CVTCIL_CPP", "Phx1600_LTCG_C", "Phx1600_LTCG_CPP", "Phx1600_LTCG_MSIL", "Phx1600_POGO_I_C", "Phx1600_POGO_I_CPP",
+	"Phx1600_POGO_O_C", "Phx1600_POGO_O_CPP", "Utc1600_C", "Utc1600_CPP", "Utc1600_CVTCIL_C", "Utc1600_CVTCIL_CPP", "Utc1600_LTCG_C", "Utc1600_LTCG_CPP",
+	"Utc1600_LTCG_MSIL", "Utc1600_POGO_I_C", "Utc1600_POGO_I_CPP", "Utc1600_POGO_O_C", "Utc1600_POGO_O_CPP", "AliasObj1010", "Cvtpgd1610", "Cvtres1010",
+	"Export1010", "Implib1010", "Linker1010", "Masm1010", "Utc1610_C", "Utc1610_CPP", "Utc1610_CVTCIL_C", "Utc1610_CVTCIL_CPP",
+	"Utc1610_LTCG_C", "Utc1610_LTCG_CPP", "Utc1610_LTCG_MSIL", "Utc1610_POGO_I_C", "Utc1610_POGO_I_CPP", "Utc1610_POGO_O_C", "Utc1610_POGO_O_CPP", "AliasObj1100",
+	"Cvtpgd1700", "Cvtres1100", "Export1100", "Implib1100", "Linker1100", "Masm1100", "Utc1700_C", "Utc1700_CPP",
+	"Utc1700_CVTCIL_C", "Utc1700_CVTCIL_CPP", "Utc1700_LTCG_C", "Utc1700_LTCG_CPP", "Utc1700_LTCG_MSIL", "Utc1700_POGO_I_C", "Utc1700_POGO_I_CPP", "Utc1700_POGO_O_C",
+	"Utc1700_POGO_O_CPP", "AliasObj1200", "Cvtpgd1800", "Cvtres1200", "Export1200", "Implib1200", "Linker1200", "Masm1200",
+	"Utc1800_C", "Utc1800_CPP", "Utc1800_CVTCIL_C", "Utc1800_CVTCIL_CPP", "Utc1800_LTCG_C", "Utc1800_LTCG_CPP", "Utc1800_LTCG_MSIL", "Utc1800_POGO_I_C",
+	"Utc1800_POGO_I_CPP", "Utc1800_POGO_O_C", "Utc1800_POGO_O_CPP", "AliasObj1210", "Cvtpgd1810", "Cvtres1210", "Export1210", "Implib1210",
+	"Linker1210", "Masm1210", "Utc1810_C", "Utc1810_CPP", "Utc1810_CVTCIL_C", "Utc1810_CVTCIL_CPP", "Utc1810_LTCG_C", "Utc1810_LTCG_CPP",
+	"Utc1810_LTCG_MSIL", "Utc1810_POGO_I_C", "Utc1810_POGO_I_CPP", "Utc1810_POGO_O_C", "Utc1810_POGO_O_CPP", "AliasObj1400", "Cvtpgd1900", "Cvtres1400",
+	"Export1400", "Implib1400", "Linker1400", "Masm1400", "Utc1900_C", "Utc1900_CPP", "Utc1900_CVTCIL_C", "Utc1900_CVTCIL_CPP",
+	"Utc1900_LTCG_C", "Utc1900_LTCG_CPP", "Utc1900_LTCG_MSIL", "Utc1900_POGO_I_C", "Utc1900_POGO_I_CPP", "Utc1900_POGO_O_C", "Utc1900_POGO_O_CPP",
+}
+
+type richEntry struct {
+	Product uint16
+	Build   uint16
+	Count   uint32
+}
+
+type richHeader struct {
+	Offset   int
+	End      int
+	Key      uint32
+	Checksum uint32
+	Entries  []richEntry
+	Clear    []byte
+}
+
+// readRichHeader decodes the xor encrypted linker tool list that sits between
+// the dos stub and the pe header, it starts with DanS and ends with Rich
+func readRichHeader(b []byte) *richHeader {
+	if len(b) < 0x40 {
+		return nil
+	}
+	lfanew := int(binary.LittleEndian.Uint32(b[0x3c:]))
+	if lfanew < 0x40 || lfanew > len(b) {
+		return nil
+	}
+
+	end := bytes.Index(b[0x40:lfanew], []byte("Rich"))
+	if end < 0 || 0x40+end+8 > lfanew {
+		return nil
+	}
+	end += 0x40
+	key := binary.LittleEndian.Uint32(b[end+4:])
+
+	start := -1
+	for i := end - 4; i >= 0x40; i -= 4 {
+		if binary.LittleEndian.Uint32(b[i:])^key == 0x536e6144 {
+			start = i
+			break
+		}
+	}
+	if start < 0 || end-start < 16 {
+		return nil
+	}
+
+	r := &richHeader{Offset: start, End: end + 8, Key: key}
+	for i := start; i < end; i += 4 {
+		r.Clear = binary.LittleEndian.AppendUint32(r.Clear, binary.LittleEndian.Uint32(b[i:])^key)
+	}
+	for p := r.Clear[16:]; len(p) >= 8; p = p[8:] {
+		id := binary.LittleEndian.Uint32(p)
+		r.Entries = append(r.Entries, richEntry{uint16(id >> 16), uint16(id), binary.LittleEndian.Uint32(p[4:])})
+	}
+
+	// the key is a checksum of the dos header (minus e_lfanew) and the entries
+	r.Checksum = uint32(start)
+	for i := 0; i < start; i++ {
+		if 0x3c <= i && i < 0x40 {
+			continue
+		}
+		r.Checksum += bits.RotateLeft32(uint32(b[i]), i)
+	}
+	for _, e := range r.Entries {
+		r.Checksum += bits.RotateLeft32(uint32(e.Product)<<16|uint32(e.Build), int(e.Count&0x1f))
+	}
+	return r
+}
+
+func dumpRichHeader(b []byte) {
+	if len(b) < 0x40 {
+		return
+	}
+	lfanew := int(binary.LittleEndian.Uint32(b[0x3c:]))
+	if lfanew > len(b) || lfanew < 0x40 {
+		return
+	}
+
+	rh := readRichHeader(b)
+	stubend := lfanew
+	if rh != nil {
+		stubend = rh.Offset
+	}
+	stub := b[0x40:stubend]
+
+	fmt.Println("DOS Stub:")
+	fmt.Printf("Range      : %#x - %#x (%d bytes)\n", 0x40, stubend, len(stub))
+	fmt.Printf("MD5        : %x\n", md5.Sum(stub))
+	if i := bytes.Index(stub, []byte("This program")); i >= 0 {
+		msg := stub[i:]
+		if j := bytes.IndexAny(msg, "$\r\n\x00"); j >= 0 {
+			msg = msg[:j]
+		}
+		fmt.Printf("Message    : %s\n", msg)
+	}
+	if *vflag {
+		fmt.Print(hex.Dump(stub))
+	}
+	fmt.Println()
+
+	if rh == nil {
+		return
+	}
+	status := "valid"
+	if rh.Checksum != rh.Key {
+		status = fmt.Sprintf("invalid, computed %#08x", rh.Checksum)
+	}
+	fmt.Printf("Rich Header: (%d entries)\n", len(rh.Entries))
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("Range      : %#x - %#x\n", rh.Offset, rh.End)
+	fmt.Printf("Key        : %#08x (%s)\n", rh.Key, status)
+	fmt.Printf("Rich Hash  : %x\n", md5.Sum(rh.Clear))
+	for _, e := range rh.Entries {
+		name := fmt.Sprintf("Product_%#04x", e.Product)
+		if int(e.Product) < len(richProducts) {
+			name = richProducts[e.Product]
+		}
+		fmt.Printf("%#04x %-24s build %-6d count %d\n", e.Product, name, e.Build, e.Count)
+	}
+	fmt.Println()
+}
+
+type importEntry struct {
+	DLL              string
+	Name             string
+	Ordinal          uint16
+	ByOrdinal        bool
+	Hint             uint16
+	DLLNameRVA       uint32
+	NameRVA          uint32
+	OriginalThunkRVA uint32
+	ThunkRVA         uint32
+}
+
+// readThunks walks an import name table, entries with the high bit set import by ordinal
+func readThunks(f *peutil.File, dll string, dllrva, oft, ft uint32) []importEntry {
+	ps := pointerSize(f)
+	ilt := oft
+	if ilt == 0 {
+		ilt = ft
+	}
+	b := readRVA(f, ilt, -1)
+
+	var r []importEntry
+	for i := uint32(0); len(b) >= ps && i < 0x10000; i, b = i+1, b[ps:] {
+		v := readPointer(b, ps)
+		if v == 0 {
+			break
+		}
+
+		e := importEntry{
+			DLL:        dll,
+			DLLNameRVA: dllrva,
+			ThunkRVA:   ft + i*uint32(ps),
+		}
+		if oft != 0 {
+			e.OriginalThunkRVA = oft + i*uint32(ps)
+		}
+		if v>>(ps*8-1) != 0 {
+			e.ByOrdinal = true
+			e.Ordinal = uint16(v)
+		} else {
+			e.NameRVA = uint32(v) & 0x7fffffff
+			if p := readRVA(f, e.NameRVA, -1); len(p) >= 2 {
+				e.Hint = binary.LittleEndian.Uint16(p)
+				e.Name = cstring(p[2:])
+			}
+		}
+		r = append(r, e)
+	}
+	return r
+}
+
+func readImports(f *peutil.File) []importEntry {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_IMPORT)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return nil
+	}
+
+	var r []importEntry
+	for b := readRVA(f, d.VirtualAddress, -1); len(b) >= 20; b = b[20:] {
+		oft := binary.LittleEndian.Uint32(b)
+		name := binary.LittleEndian.Uint32(b[12:])
+		ft := binary.LittleEndian.Uint32(b[16:])
+		if oft == 0 && name == 0 && ft == 0 {
+			break
+		}
+		r = append(r, readThunks(f, cstring(readRVA(f, name, -1)), name, oft, ft)...)
+	}
+	return r
+}
+
+// readDelayImports reads ImgDelayDescr entries, the original
+// visual c++ 6 layout stores virtual addresses instead of rvas
+func readDelayImports(f *peutil.File) []importEntry {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_DELAY_IMPORT)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return nil
+	}
+
+	var r []importEntry
+	for b := readRVA(f, d.VirtualAddress, -1); len(b) >= 32; b = b[32:] {
+		attrs := binary.LittleEndian.Uint32(b)
+		name := binary.LittleEndian.Uint32(b[4:])
+		iat := binary.LittleEndian.Uint32(b[12:])
+		int_ := binary.LittleEndian.Uint32(b[16:])
+		if name == 0 && iat == 0 && int_ == 0 {
+			break
+		}
+		if attrs&1 == 0 {
+			base := uint32(f.ImageBase)
+			name -= base
+			iat -= base
+			int_ -= base
+		}
+		r = append(r, readThunks(f, cstring(readRVA(f, name, -1)), name, int_, iat)...)
+	}
+	return r
+}
+
+func dumpImports(f *peutil.File, title string, imps []importEntry) {
+	fmt.Printf("%s: (%d symbols)\n", title, len(imps))
+	fmt.Println(strings.Repeat("-", 80))
+	for _, y := range imps {
+		name := y.Name
+		if y.ByOrdinal {
+			name = fmt.Sprintf("#%d", y.Ordinal)
+		}
+		rvas := []uint32{y.DLLNameRVA, y.NameRVA, y.OriginalThunkRVA, y.ThunkRVA}
+		var a [4]string
+		for i, rva := range rvas {
+			if rva != 0 {
+				a[i] = sectionName(f, uint64(rva))
+			}
+		}
+		fmt.Printf("%-16s %-70s %#08x %s %#08x %s %#08x %s %#08x %s\n",
+			y.DLL, name, f.ImageBase+uint64(y.DLLNameRVA), a[0], f.ImageBase+uint64(y.NameRVA), a[1], f.ImageBase+uint64(y.OriginalThunkRVA), a[2], f.ImageBase+uint64(y.ThunkRVA), a[3])
+	}
+	fmt.Println()
+}
+
+// dumpBoundImports decodes the bound import directory, it usually
+// lives in the headers past the section table so it is read from the file
+func dumpBoundImports(f *peutil.File, raw []byte) {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_BOUND_IMPORT)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return
+	}
+	b := readRVA(f, d.VirtualAddress, int(d.Size))
+	if b == nil && uint64(d.VirtualAddress)+uint64(d.Size) <= uint64(len(raw)) {
+		b = raw[d.VirtualAddress : d.VirtualAddress+d.Size]
+	}
+	name := func(off uint16) string {
+		if int(off) < len(b) {
+			return cstring(b[off:])
+		}
+		return ""
+	}
+
+	fmt.Println("Bound Imports:")
+	fmt.Println(strings.Repeat("-", 80))
+	for p := b; len(p) >= 8; {
+		ts := binary.LittleEndian.Uint32(p)
+		off := binary.LittleEndian.Uint16(p[4:])
+		nref := int(binary.LittleEndian.Uint16(p[6:]))
+		if ts == 0 && off == 0 {
+			break
+		}
+		fmt.Printf("%-24s %#08x\n", name(off), ts)
+		p = p[8:]
+		for i := 0; i < nref && len(p) >= 8; i, p = i+1, p[8:] {
+			fmt.Printf("    forwarder %-24s %#08x\n", name(binary.LittleEndian.Uint16(p[4:])), binary.LittleEndian.Uint32(p))
+		}
+	}
+	fmt.Println()
+}
+
+type exportEntry struct {
+	Ordinal   uint32
+	Name      string
+	RVA       uint32
+	Forwarder string
+}
+
+// readExports returns the exports in ordinal order, addresses inside
+// the export directory point to a forwarder string instead of code
+func readExports(f *peutil.File) []exportEntry {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_EXPORT)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return nil
+	}
+	b := readRVA(f, d.VirtualAddress, -1)
+	if len(b) < 40 {
+		return nil
+	}
+
+	base := binary.LittleEndian.Uint32(b[16:])
+	nfuncs := binary.LittleEndian.Uint32(b[20:])
+	nnames := binary.LittleEndian.Uint32(b[24:])
+	funcs := readRVA(f, binary.LittleEndian.Uint32(b[28:]), -1)
+	names := readRVA(f, binary.LittleEndian.Uint32(b[32:]), -1)
+	ords := readRVA(f, binary.LittleEndian.Uint32(b[36:]), -1)
+
+	fnames := make(map[uint32]string)
+	for i := uint32(0); i < nnames && 4*i+4 <= uint32(len(names)) && 2*i+2 <= uint32(len(ords)); i++ {
+		idx := uint32(binary.LittleEndian.Uint16(ords[2*i:]))
+		if _, found := fnames[idx]; !found {
+			fnames[idx] = cstring(readRVA(f, binary.LittleEndian.Uint32(names[4*i:]), -1))
+		}
+	}
+
+	var r []exportEntry
+	for i := uint32(0); i < nfuncs && 4*i+4 <= uint32(len(funcs)); i++ {
+		rva := binary.LittleEndian.Uint32(funcs[4*i:])
+		if rva == 0 {
+			continue
+		}
+		e := exportEntry{
+			Ordinal: base + i,
+			Name:    fnames[i],
+			RVA:     rva,
+		}
+		if d.VirtualAddress <= rva && rva < d.VirtualAddress+d.Size {
+			e.Forwarder = cstring(readRVA(f, rva, -1))
+		}
+		r = append(r, e)
+	}
+	return r
+}
+
+// ws2_32, wsock32 and oleaut32 are commonly imported by ordinal,
+// imphash resolves them to names so the hash does not depend on it
+var winsockOrdinals = map[uint16]string{
+	1: "accept", 2: "bind", 3: "closesocket", 4: "connect", 5: "getpeername", 6: "getsockname",
+	7: "getsockopt", 8: "htonl", 9: "htons", 10: "ioctlsocket", 11: "inet_addr", 12: "inet_ntoa",
+	13: "listen", 14: "ntohl", 15: "ntohs", 16: "recv", 17: "recvfrom", 18: "select",
+	19: "send", 20: "sendto", 21: "setsockopt", 22: "shutdown", 23: "socket",
+	51: "gethostbyaddr", 52: "gethostbyname", 53: "getprotobyname", 54: "getprotobynumber",
+	55: "getservbyname", 56: "getservbyport", 57: "gethostname",
+	101: "WSAAsyncSelect", 102: "WSAAsyncGetHostByAddr", 103: "WSAAsyncGetHostByName",
+	104: "WSAAsyncGetProtoByNumber", 105: "WSAAsyncGetProtoByName", 106: "WSAAsyncGetServByPort",
+	107: "WSAAsyncGetServByName", 108: "WSACancelAsyncRequest", 109: "WSASetBlockingHook",
+	110: "WSAUnhookBlockingHook", 111: "WSAGetLastError", 112: "WSASetLastError",
+	113: "WSACancelBlockingCall", 114: "WSAIsBlocking", 115: "WSAStartup", 116: "WSACleanup",
+	151: "__WSAFDIsSet",
+}
+
+var oleaut32Ordinals = map[uint16]string{
+	2: "SysAllocString", 3: "SysReAllocString", 4: "SysAllocStringLen", 5: "SysReAllocStringLen",
+	6: "SysFreeString", 7: "SysStringLen", 8: "VariantInit", 9: "VariantClear", 10: "VariantCopy",
+	11: "VariantCopyInd", 12: "VariantChangeType", 13: "VariantTimeToDosDateTime",
+	14: "DosDateTimeToVariantTime", 15: "SafeArrayCreate", 16: "SafeArrayDestroy",
+	17: "SafeArrayGetDim", 18: "SafeArrayGetElemsize", 19: "SafeArrayGetUBound",
+	20: "SafeArrayGetLBound", 21: "SafeArrayLock", 22: "SafeArrayUnlock", 23: "SafeArrayAccessData",
+	24: "SafeArrayUnaccessData", 25: "SafeArrayGetElement", 26: "SafeArrayPutElement",
+	27: "SafeArrayCopy", 28: "DispGetParam", 29: "DispGetIDsOfNames", 30: "DispInvoke",
+	31: "CreateDispTypeInfo", 32: "CreateStdDispatch", 33: "RegisterActiveObject",
+	34: "RevokeActiveObject", 35: "GetActiveObject", 36: "SafeArrayAllocDescriptor",
+	37: "SafeArrayAllocData", 38: "SafeArrayDestroyDescriptor", 39: "SafeArrayDestroyData",
+	40: "SafeArrayRedim", 41: "SafeArrayAllocDescriptorEx", 42: "SafeArrayCreateEx",
+	43: "SafeArrayCreateVectorEx", 44: "SafeArraySetRecordInfo", 45: "SafeArrayGetRecordInfo",
+	46: "VarParseNumFromStr", 47: "VarNumFromParseNum", 48: "VarI2FromUI1", 49: "VarI2FromI4",
+	50: "VarI2FromR4", 51: "VarI2FromR8", 52: "VarI2FromCy", 53: "VarI2FromDate", 54: "VarI2FromStr",
+	55: "VarI2FromDisp", 56: "VarI2FromBool", 57: "SafeArraySetIID", 58: "VarI4FromUI1",
+	59: "VarI4FromI2", 60: "VarI4FromR4", 61: "VarI4FromR8", 62: "VarI4FromCy", 63: "VarI4FromDate",
+	64: "VarI4FromStr", 65: "VarI4FromDisp", 66: "VarI4FromBool", 67: "SafeArrayGetIID",
+	68: "VarR4FromUI1", 69: "VarR4FromI2", 70: "VarR4FromI4", 71: "VarR4FromR8", 72: "VarR4FromCy",
+	73: "VarR4FromDate", 74: "VarR4FromStr", 75: "VarR4FromDisp", 76: "VarR4FromBool",
+	77: "SafeArrayGetVartype", 78: "VarR8FromUI1", 79: "VarR8FromI2", 80: "VarR8FromI4",
+	81: "VarR8FromR4", 82: "VarR8FromCy", 83: "VarR8FromDate", 84: "VarR8FromStr", 85: "VarR8FromDisp",
+	86: "VarR8FromBool", 87: "VarFormat", 88: "VarDateFromUI1", 89: "VarDateFromI2",
+	90: "VarDateFromI4", 91: "VarDateFromR4", 92: "VarDateFromR8", 93: "VarDateFromCy",
+	94: "VarDateFromStr", 95: "VarDateFromDisp", 96: "VarDateFromBool", 97: "VarFormatDateTime",
+	98: "VarCyFromUI1", 99: "VarCyFromI2", 100: "VarCyFromI4", 101: "VarCyFromR4", 102: "VarCyFromR8",
+	103: "VarCyFromDate", 104: "VarCyFromStr", 105: "VarCyFromDisp", 106: "VarCyFromBool",
+	107: "VarFormatNumber", 108: "VarBstrFromUI1", 109: "VarBstrFromI2", 110: "VarBstrFromI4",
+	111: "VarBstrFromR4", 112: "VarBstrFromR8", 113: "VarBstrFromCy", 114: "VarBstrFromDate",
+	115: "VarBstrFromDisp", 116: "VarBstrFromBool", 117: "VarFormatPercent", 118: "VarBoolFromUI1",
+	119: "VarBoolFromI2", 120: "VarBoolFromI4", 121: "VarBoolFromR4", 122: "VarBoolFromR8",
+	123: "VarBoolFromDate", 124: "VarBoolFromCy", 125: "VarBoolFromStr", 126: "VarBoolFromDisp",
+	127: "VarFormatCurrency", 128: "VarWeekdayName", 129: "VarMonthName", 130: "VarUI1FromI2",
+	131: "VarUI1FromI4", 132: "VarUI1FromR4", 133: "VarUI1FromR8", 134: "VarUI1FromCy",
+	135: "VarUI1FromDate", 136: "VarUI1FromStr", 137: "VarUI1FromDisp", 138: "VarUI1FromBool",
+	139: "VarFormatFromTokens", 140: "VarTokenizeFormatString", 141: "VarAdd", 142: "VarAnd",
+	143: "VarDiv", 144: "DllCanUnloadNow", 145: "DllGetClassObject", 146: "DispCallFunc",
+	147: "VariantChangeTypeEx", 148: "SafeArrayPtrOfIndex", 149: "SysStringByteLen",
+	150: "SysAllocStringByteLen", 151: "DllRegisterServer", 152: "VarEqv", 153: "VarIdiv",
+	154: "VarImp", 155: "VarMod", 156: "VarMul", 157: "VarOr", 158: "VarPow", 159: "VarSub",
+	160: "CreateTypeLib", 161: "LoadTypeLib", 162: "LoadRegTypeLib", 163: "RegisterTypeLib",
+	164: "QueryPathOfRegTypeLib", 165: "LHashValOfNameSys", 166: "LHashValOfNameSysA", 167: "VarXor",
+	168: "VarAbs", 169: "VarFix", 170: "OaBuildVersion", 171: "ClearCustData", 172: "VarInt",
+	173: "VarNeg", 174: "VarNot", 175: "VarRound", 176: "VarCmp", 177: "VarDecAdd", 178: "VarDecDiv",
+	179: "VarDecMul", 180: "CreateTypeLib2", 181: "VarDecSub", 182: "VarDecAbs", 183: "LoadTypeLibEx",
+	184: "SystemTimeToVariantTime", 185: "VariantTimeToSystemTime", 186: "UnRegisterTypeLib",
+	187: "VarDecFix", 188: "VarDecInt", 189: "VarDecNeg", 190: "VarDecFromUI1", 191: "VarDecFromI2",
+	192: "VarDecFromI4", 193: "VarDecFromR4", 194: "VarDecFromR8", 195: "VarDecFromDate",
+	196: "VarDecFromCy", 197: "VarDecFromStr", 198: "VarDecFromDisp", 199: "VarDecFromBool",
+	200: "GetErrorInfo", 201: "SetErrorInfo", 202: "CreateErrorInfo", 203: "VarDecRound",
+	204: "VarDecCmp", 205: "VarI2FromI1", 206: "VarI2FromUI2", 207: "VarI2FromUI4",
+	208: "VarI2FromDec", 209: "VarI4FromI1", 210: "VarI4FromUI2", 211: "VarI4FromUI4",
+	212: "VarI4FromDec", 213: "VarR4FromI1", 214: "VarR4FromUI2", 215: "VarR4FromUI4",
+	216: "VarR4FromDec", 217: "VarR8FromI1", 218: "VarR8FromUI2", 219: "VarR8FromUI4",
+	220: "VarR8FromDec", 221: "VarDateFromI1", 222: "VarDateFromUI2", 223: "VarDateFromUI4",
+	224: "VarDateFromDec", 225: "VarCyFromI1", 226: "VarCyFromUI2", 227: "VarCyFromUI4",
+	228: "VarCyFromDec", 229: "VarBstrFromI1", 230: "VarBstrFromUI2", 231: "VarBstrFromUI4",
+	232: "VarBstrFromDec", 233: "VarBoolFromI1", 234: "VarBoolFromUI2", 235: "VarBoolFromUI4",
+	236: "VarBoolFromDec", 237: "VarUI1FromI1", 238: "VarUI1FromUI2", 239: "VarUI1FromUI4",
+	240: "VarUI1FromDec", 241: "VarDecFromI1", 242: "VarDecFromUI2", 243: "VarDecFromUI4",
+	244: "VarI1FromUI1", 245: "VarI1FromI2", 246: "VarI1FromI4", 247: "VarI1FromR4",
+	248: "VarI1FromR8", 249: "VarI1FromDate", 250: "VarI1FromCy", 251: "VarI1FromStr",
+	252: "VarI1FromDisp", 253: "VarI1FromBool", 254: "VarI1FromUI2", 255: "VarI1FromUI4",
+	256: "VarI1FromDec", 257: "VarUI2FromUI1", 258: "VarUI2FromI2", 259: "VarUI2FromI4",
+	260: "VarUI2FromR4", 261: "VarUI2FromR8", 262: "VarUI2FromDate", 263: "VarUI2FromCy",
+	264: "VarUI2FromStr", 265: "VarUI2FromDisp", 266: "VarUI2FromBool", 267: "VarUI2FromI1",
+	268: "VarUI2FromUI4", 269: "VarUI2FromDec", 270: "VarUI4FromUI1", 271: "VarUI4FromI2",
+	272: "VarUI4FromI4", 273: "VarUI4FromR4", 274: "VarUI4FromR8", 275: "VarUI4FromDate",
+	276: "VarUI4FromCy", 277: "VarUI4FromStr", 278: "VarUI4FromDisp", 279: "VarUI4FromBool",
+	280: "VarUI4FromI1", 281: "VarUI4FromUI2", 282: "VarUI4FromDec", 283: "BSTR_UserSize",
+	284: "BSTR_UserMarshal", 285: "BSTR_UserUnmarshal", 286: "BSTR_UserFree", 287: "VARIANT_UserSize",
+	288: "VARIANT_UserMarshal", 289: "VARIANT_UserUnmarshal", 290: "VARIANT_UserFree",
+	291: "LPSAFEARRAY_UserSize", 292: "LPSAFEARRAY_UserMarshal", 293: "LPSAFEARRAY_UserUnmarshal",
+	294: "LPSAFEARRAY_UserFree", 295: "LPSAFEARRAY_Size", 296: "LPSAFEARRAY_Marshal",
+	297: "LPSAFEARRAY_Unmarshal", 298: "VarDecCmpR8", 299: "VarCyAdd", 300: "DllUnregisterServer",
+	301: "OACreateTypeLib2", 303: "VarCyMul", 304: "VarCyMulI4", 305: "VarCySub", 306: "VarCyAbs",
+	307: "VarCyFix", 308: "VarCyInt", 309: "VarCyNeg", 310: "VarCyRound", 311: "VarCyCmp",
+	312: "VarCyCmpR8", 313: "VarBstrCat", 314: "VarBstrCmp", 315: "VarR8Pow", 316: "VarR4CmpR8",
+	317: "VarR8Round", 318: "VarCat", 319: "VarDateFromUdateEx", 322: "GetRecordInfoFromGuids",
+	323: "GetRecordInfoFromTypeInfo", 325: "SetVarConversionLocaleSetting",
+	326: "GetVarConversionLocaleSetting", 327: "SetOaNoCache", 329: "VarCyMulI8",
+	330: "VarDateFromUdate", 331: "VarUdateFromDate", 332: "GetAltMonthNames", 333: "VarI8FromUI1",
+	334: "VarI8FromI2", 335: "VarI8FromR4", 336: "VarI8FromR8", 337: "VarI8FromCy",
+	338: "VarI8FromDate", 339: "VarI8FromStr", 340: "VarI8FromDisp", 341: "VarI8FromBool",
+	342: "VarI8FromI1", 343: "VarI8FromUI2", 344: "VarI8FromUI4", 345: "VarI8FromDec",
+	346: "VarI2FromI8", 347: "VarI2FromUI8", 348: "VarI4FromI8", 349: "VarI4FromUI8",
+	360: "VarR4FromI8", 361: "VarR4FromUI8", 362: "VarR8FromI8", 363: "VarR8FromUI8",
+	364: "VarDateFromI8", 365: "VarDateFromUI8", 366: "VarCyFromI8", 367: "VarCyFromUI8",
+	368: "VarBstrFromI8", 369: "VarBstrFromUI8", 370: "VarBoolFromI8", 371: "VarBoolFromUI8",
+	372: "VarUI1FromI8", 373: "VarUI1FromUI8", 374: "VarDecFromI8", 375: "VarDecFromUI8",
+	376: "VarI1FromI8", 377: "VarI1FromUI8", 378: "VarUI2FromI8", 379: "VarUI2FromUI8",
+	401: "OleLoadPictureEx", 402: "OleLoadPictureFileEx", 411: "SafeArrayCreateVector",
+	412: "SafeArrayCopyData", 413: "VectorFromBstr", 414: "BstrFromVector", 415: "OleIconToCursor",
+	416: "OleCreatePropertyFrameIndirect", 417: "OleCreatePropertyFrame", 418: "OleLoadPicture",
+	419: "OleCreatePictureIndirect", 420: "OleCreateFontIndirect", 421: "OleTranslateColor",
+	422: "OleLoadPictureFile", 423: "OleSavePictureFile", 424: "OleLoadPicturePath",
+	425: "VarUI4FromI8", 426: "VarUI4FromUI8", 427: "VarI8FromUI8", 428: "VarUI8FromI8",
+	429: "VarUI8FromUI1", 430: "VarUI8FromI2", 431: "VarUI8FromR4", 432: "VarUI8FromR8",
+	433: "VarUI8FromCy", 434: "VarUI8FromDate", 435: "VarUI8FromStr", 436: "VarUI8FromDisp",
+	437: "VarUI8FromBool", 438: "VarUI8FromI1", 439: "VarUI8FromUI2", 440: "VarUI8FromUI4",
+	441: "VarUI8FromDec", 442: "RegisterTypeLibForUser", 443: "UnRegisterTypeLibForUser",
+}
+
+// imphash is the md5 of the comma separated lib.func list in import order,
+// with the library extension dropped and everything lower cased
+func imphash(imps []importEntry) string {
+	if len(imps) == 0 {
+		return ""
+	}
+
+	var p []string
+	for _, y := range imps {
+		lib := strings.ToLower(y.DLL)
+		if i := strings.LastIndexByte(lib, '.'); i >= 0 {
+			switch lib[i+1:] {
+			case "dll", "ocx", "sys":
+				lib = lib[:i]
+			}
+		}
+
+		name := y.Name
+		if y.ByOrdinal {
+			name = fmt.Sprintf("ord%d", y.Ordinal)
+			var s string
+			switch lib {
+			case "ws2_32", "wsock32":
+				s = winsockOrdinals[y.Ordinal]
+			case "oleaut32":
+				s = oleaut32Ordinals[y.Ordinal]
+			}
+			if s != "" {
+				name = s
+			}
+		}
+		p = append(p, lib+"."+strings.ToLower(name))
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(p, ","))))
+}
+
+// exphash is the md5 of the comma separated lower cased export names in ordinal order
+func exphash(exps []exportEntry) string {
+	var p []string
+	for _, y := range exps {
+		if y.Name != "" {
+			p = append(p, strings.ToLower(y.Name))
+		}
+	}
+	if len(p) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(p, ","))))
+}
+
+// entropy is the shannon entropy in bits per byte, packed
+// or encrypted data sits close to 8
+func entropy(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+
+	var n [256]int
+	for _, c := range b {
+		n[c]++
+	}
+
+	e := 0.0
+	for _, c := range n {
+		if c != 0 {
+			p := float64(c) / float64(len(b))
+			e -= p * math.Log2(p)
+		}
+	}
+	return e
+}
+
+type overlay struct {
+	offset int64
+	size   int64
+	certs  bool
+}
+
+// findOverlay locates the data appended after the last section's raw data,
+// the authenticode certificate table is stored there as well so it is noted
+func findOverlay(f *peutil.File, raw []byte) overlay {
+	var end int64
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		end = int64(h.SizeOfHeaders)
+	case *pe.OptionalHeader64:
+		end = int64(h.SizeOfHeaders)
+	}
+	for _, s := range f.Sections {
+		if s.Size != 0 {
+			end = max(end, int64(s.Offset)+int64(s.Size))
+		}
+	}
+
+	o := overlay{offset: end}
+	if end < int64(len(raw)) {
+		o.size = int64(len(raw)) - end
+	}
+	if d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_SECURITY); d != nil && d.Size != 0 {
+		o.certs = int64(d.VirtualAddress) >= end && int64(d.VirtualAddress) < end+o.size
+	}
+	return o
+}
+
+func extractOverlay(f *peutil.File, name, out string) {
+	raw, err := os.ReadFile(name)
+	ck(err)
+
+	o := findOverlay(f, raw)
+	if o.size == 0 {
+		log.Fatalf("%s: no overlay", name)
+	}
+	ck(os.WriteFile(out, raw[o.offset:], 0644))
+	fmt.Printf("Wrote out overlay %s (%d bytes at %#x)\n", out, o.size, o.offset)
+}
+
+var packerSections = map[string]string{
+	"UPX0":       "UPX",
+	"UPX1":       "UPX",
+	"UPX2":       "UPX",
+	"UPX!":       "UPX",
+	".aspack":    "ASPack",
+	".adata":     "ASPack",
+	".ASPack":    "ASPack",
+	"ASPack":     "ASPack",
+	".MPRESS1":   "MPRESS",
+	".MPRESS2":   "MPRESS",
+	".petite":    "Petite",
+	"petite":     "Petite",
+	".nsp0":      "NsPack",
+	".nsp1":      "NsPack",
+	".nsp2":      "NsPack",
+	"nsp0":       "NsPack",
+	"nsp1":       "NsPack",
+	"nsp2":       "NsPack",
+	"pec1":       "PECompact",
+	"pec2":       "PECompact",
+	"PEC2":       "PECompact",
+	"PEC2TO":     "PECompact",
+	"PECompact2": "PECompact",
+	".themida":   "Themida",
+	".winlice":   "WinLicense",
+	".vmp0":      "VMProtect",
+	".vmp1":      "VMProtect",
+	".vmp2":      "VMProtect",
+	".enigma1":   "Enigma",
+	".enigma2":   "Enigma",
+	".yP":        "Y0da Protector",
+	".y0da":      "Y0da Protector",
+	"FSG!":       "FSG",
+	"MEW":        "MEW",
+	"kkrunchy":   "kkrunchy",
+	".packed":    "RLPack",
+	".RLPack":    "RLPack",
+	".perplex":   "Perplex",
+	".spack":     "Simple Pack",
+	".taz":       "PESpin",
+	".boom":      "The Boomerang",
+	".ccg":       "CCG",
+	".svkp":      "SVKP",
+	"ProCrypt":   "ProCrypt",
+	".Upack":     "Upack",
+	".ByDwing":   "Upack",
+	".exe32":     "EXE32Pack",
+	"PELOCKnt":   "PELock",
+	".pelock":    "PELock",
+	".sforce3":   "StarForce",
+	".securom":   "SecuROM",
+	".neolite":   "NeoLite",
+	".gentee":    "Gentee",
+	".shrink1":   "Shrinker",
+	".shrink2":   "Shrinker",
+	".shrink3":   "Shrinker",
+	".WWPACK":    "WWPack",
+	".WWP32":     "WWPack",
+}
+
+// findAnomalies flags header and section properties that compilers
+// do not normally produce but packers and hand made images do
+func findAnomalies(f *peutil.File, raw []byte) []string {
+	var r []string
+
+	oh := getOptionalInfo(f)
+	var imgend uint64
+	for _, s := range f.Sections {
+		ch := s.Characteristics
+		if ch&pe.IMAGE_SCN_MEM_WRITE != 0 && ch&pe.IMAGE_SCN_MEM_EXECUTE != 0 {
+			r = append(r, fmt.Sprintf("section %q is writable and executable", s.Name))
+		}
+		if s.Size == 0 && s.VirtualSize >= 0x10000 && ch&pe.IMAGE_SCN_CNT_UNINITIALIZED_DATA == 0 {
+			r = append(r, fmt.Sprintf("section %q has no raw data but a virtual size of %#x", s.Name, s.VirtualSize))
+		}
+		if p, found := packerSections[s.Name]; found {
+			r = append(r, fmt.Sprintf("section %q is a known %s section name", s.Name, p))
+		}
+		if int64(s.Offset)+int64(s.Size) > int64(len(raw)) {
+			r = append(r, fmt.Sprintf("section %q raw data extends past the end of file", s.Name))
+		}
+		imgend = max(imgend, uint64(s.VirtualAddress)+uint64(max(s.VirtualSize, s.Size)))
+	}
+
+	if f.OptionalHeader != nil && oh.entry != oh.imgbase {
+		s, _, _ := f.LookupVirtualAddress(oh.entry - oh.imgbase)
+		switch {
+		case s == nil:
+			r = append(r, fmt.Sprintf("entry point %#x is outside of all sections", oh.entry))
+		case s.Name != ".text":
+			r = append(r, fmt.Sprintf("entry point %#x is in section %q instead of .text", oh.entry, s.Name))
+		}
+		if s != nil && s.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE == 0 {
+			r = append(r, fmt.Sprintf("entry point %#x is in non executable section %q", oh.entry, s.Name))
+		}
+	}
+
+	if a := uint64(f.SectionAlignment); f.OptionalHeader != nil && a != 0 && len(f.Sections) > 0 {
+		want := (imgend + a - 1) &^ (a - 1)
+		if oh.imgsize != want {
+			r = append(r, fmt.Sprintf("SizeOfImage is %#x but the sections end at %#x", oh.imgsize, want))
+		}
+	}
+
+	return r
+}
+
+func dumpTriage(f *peutil.File, raw []byte) {
+	fmt.Println("Triage:")
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("File Entropy         : %.4f\n", entropy(raw))
+	if o := findOverlay(f, raw); o.size > 0 {
+		fmt.Printf("Overlay              : %#x - %#x (%d bytes)\n", o.offset, o.offset+o.size, o.size)
+		fmt.Printf("Overlay Entropy      : %.4f\n", entropy(raw[o.offset:]))
+		if o.certs {
+			fmt.Printf("Overlay Contents     : certificate table\n")
+		}
+	}
+	fmt.Println()
+
+	anomalies := findAnomalies(f, raw)
+	fmt.Printf("Anomalies: (%d)\n", len(anomalies))
+	for _, a := range anomalies {
+		fmt.Println(a)
+	}
+	fmt.Println()
+}
+
+const (
+	COMIMAGE_FLAGS_ILONLY            = 0x1
+	COMIMAGE_FLAGS_32BITREQUIRED     = 0x2
+	COMIMAGE_FLAGS_IL_LIBRARY        = 0x4
+	COMIMAGE_FLAGS_STRONGNAMESIGNED  = 0x8
+	COMIMAGE_FLAGS_NATIVE_ENTRYPOINT = 0x10
+	COMIMAGE_FLAGS_TRACKDEBUGDATA    = 0x10000
+	COMIMAGE_FLAGS_32BITPREFERRED    = 0x20000
+)
+
+var clrFlags = []struct {
+	bit uint32
+	str string
+}{
+	{COMIMAGE_FLAGS_ILONLY, "COMIMAGE_FLAGS_ILONLY"},
+	{COMIMAGE_FLAGS_32BITREQUIRED, "COMIMAGE_FLAGS_32BITREQUIRED"},
+	{COMIMAGE_FLAGS_IL_LIBRARY, "COMIMAGE_FLAGS_IL_LIBRARY"},
+	{COMIMAGE_FLAGS_STRONGNAMESIGNED, "COMIMAGE_FLAGS_STRONGNAMESIGNED"},
+	{COMIMAGE_FLAGS_NATIVE_ENTRYPOINT, "COMIMAGE_FLAGS_NATIVE_ENTRYPOINT"},
+	{COMIMAGE_FLAGS_TRACKDEBUGDATA, "COMIMAGE_FLAGS_TRACKDEBUGDATA"},
+	{COMIMAGE_FLAGS_32BITPREFERRED, "COMIMAGE_FLAGS_32BITPREFERRED"},
+}
+
+type clrHeader struct {
+	Cb                      uint32
+	MajorRuntimeVersion     uint16
+	MinorRuntimeVersion     uint16
+	MetaData                pe.DataDirectory
+	Flags                   uint32
+	EntryPoint              uint32
+	Resources               pe.DataDirectory
+	StrongNameSignature     pe.DataDirectory
+	CodeManagerTable        pe.DataDirectory
+	VTableFixups            pe.DataDirectory
+	ExportAddressTableJumps pe.DataDirectory
+	ManagedNativeHeader     pe.DataDirectory
+}
+
+// metadata table numbers from ecma-335 partition ii section 22
+const (
+	mdModule = iota
+	mdTypeRef
+	mdTypeDef
+	mdFieldPtr
+	mdField
+	mdMethodPtr
+	mdMethodDef
+	mdParamPtr
+	mdParam
+	mdInterfaceImpl
+	mdMemberRef
+	mdConstant
+	mdCustomAttribute
+	mdFieldMarshal
+	mdDeclSecurity
+	mdClassLayout
+	mdFieldLayout
+	mdStandAloneSig
+	mdEventMap
+	mdEventPtr
+	mdEvent
+	mdPropertyMap
+	mdPropertyPtr
+	mdProperty
+	mdMethodSemantics
+	mdMethodImpl
+	mdModuleRef
+	mdTypeSpec
+	mdImplMap
+	mdFieldRVA
+	mdEncLog
+	mdEncMap
+	mdAssembly
+	mdAssemblyProcessor
+	mdAssemblyOS
+	mdAssemblyRef
+	mdAssemblyRefProcessor
+	mdAssemblyRefOS
+	mdFile
+	mdExportedType
+	mdManifestResource
+	mdNestedClass
+	mdGenericParam
+	mdMethodSpec
+	mdGenericParamConstraint
+	mdMaxTable
+)
+
+var mdTableNames = []string{
+	"Module", "TypeRef", "TypeDef", "FieldPtr", "Field", "MethodPtr", "MethodDef", "ParamPtr",
+	"Param", "InterfaceImpl", "MemberRef", "Constant", "CustomAttribute", "FieldMarshal",
+	"DeclSecurity", "ClassLayout", "FieldLayout", "StandAloneSig", "EventMap", "EventPtr",
+	"Event", "PropertyMap", "PropertyPtr", "Property", "MethodSemantics", "MethodImpl",
+	"ModuleRef", "TypeSpec", "ImplMap", "FieldRVA", "EncLog", "EncMap", "Assembly",
+	"AssemblyProcessor", "AssemblyOS", "AssemblyRef", "AssemblyRefProcessor", "AssemblyRefOS",
+	"File", "ExportedType", "ManifestResource", "NestedClass", "GenericParam", "MethodSpec",
+	"GenericParamConstraint",
+}
+
+// column kinds, values below mdU16 are indexes into that table
+const (
+	mdU16 = 0x100 + iota
+	mdU32
+	mdString
+	mdGUID
+	mdBlob
+	mdTypeDefOrRef
+	mdHasConstant
+	mdHasCustomAttribute
+	mdHasFieldMarshal
+	mdHasDeclSecurity
+	mdMemberRefParent
+	mdHasSemantics
+	mdMethodDefOrRef
+	mdMemberForwarded
+	mdImplementation
+	mdCustomAttributeType
+	mdResolutionScope
+	mdTypeOrMethodDef
+)
+
+// coded index tag widths and the tables they select, -1 marks an unused tag
+var mdCodedIndexes = map[int]struct {
+	bits   uint
+	tables []int
+}{
+	mdTypeDefOrRef: {2, []int{mdTypeDef, mdTypeRef, mdTypeSpec}},
+	mdHasConstant:  {2, []int{mdField, mdParam, mdProperty}},
+	mdHasCustomAttribute: {5, []int{
+		mdMethodDef, mdField, mdTypeRef, mdTypeDef, mdParam, mdInterfaceImpl, mdMemberRef,
+		mdModule, mdDeclSecurity, mdProperty, mdEvent, mdStandAloneSig, mdModuleRef, mdTypeSpec,
+		mdAssembly, mdAssemblyRef, mdFile, mdExportedType, mdManifestResource, mdGenericParam,
+		mdGenericParamConstraint, mdMethodSpec,
+	}},
+	mdHasFieldMarshal:     {1, []int{mdField, mdParam}},
+	mdHasDeclSecurity:     {2, []int{mdTypeDef, mdMethodDef, mdAssembly}},
+	mdMemberRefParent:     {3, []int{mdTypeDef, mdTypeRef, mdModuleRef, mdMethodDef, mdTypeSpec}},
+	mdHasSemantics:        {1, []int{mdEvent, mdProperty}},
+	mdMethodDefOrRef:      {1, []int{mdMethodDef, mdMemberRef}},
+	mdMemberForwarded:     {1, []int{mdField, mdMethodDef}},
+	mdImplementation:      {2, []int{mdFile, mdAssemblyRef, mdExportedType}},
+	mdCustomAttributeType: {3, []int{-1, -1, mdMethodDef, mdMemberRef, -1}},
+	mdResolutionScope:     {2, []int{mdModule, mdModuleRef, mdAssemblyRef, mdTypeRef}},
+	mdTypeOrMethodDef:     {1, []int{mdTypeDef, mdMethodDef}},
+}
+
+var mdSchema = [mdMaxTable][]int{
+	mdModule:                 {mdU16, mdString, mdGUID, mdGUID, mdGUID},
+	mdTypeRef:                {mdResolutionScope, mdString, mdString},
+	mdTypeDef:                {mdU32, mdString, mdString, mdTypeDefOrRef, mdField, mdMethodDef},
+	mdFieldPtr:               {mdField},
+	mdField:                  {mdU16, mdString, mdBlob},
+	mdMethodPtr:              {mdMethodDef},
+	mdMethodDef:              {mdU32, mdU16, mdU16, mdString, mdBlob, mdParam},
+	mdParamPtr:               {mdParam},
+	mdParam:                  {mdU16, mdU16, mdString},
+	mdInterfaceImpl:          {mdTypeDef, mdTypeDefOrRef},
+	mdMemberRef:              {mdMemberRefParent, mdString, mdBlob},
+	mdConstant:               {mdU16, mdHasConstant, mdBlob},
+	mdCustomAttribute:        {mdHasCustomAttribute, mdCustomAttributeType, mdBlob},
+	mdFieldMarshal:           {mdHasFieldMarshal, mdBlob},
+	mdDeclSecurity:           {mdU16, mdHasDeclSecurity, mdBlob},
+	mdClassLayout:            {mdU16, mdU32, mdTypeDef},
+	mdFieldLayout:            {mdU32, mdField},
+	mdStandAloneSig:          {mdBlob},
+	mdEventMap:               {mdTypeDef, mdEvent},
+	mdEventPtr:               {mdEvent},
+	mdEvent:                  {mdU16, mdString, mdTypeDefOrRef},
+	mdPropertyMap:            {mdTypeDef, mdProperty},
+	mdPropertyPtr:            {mdProperty},
+	mdProperty:               {mdU16, mdString, mdBlob},
+	mdMethodSemantics:        {mdU16, mdMethodDef, mdHasSemantics},
+	mdMethodImpl:             {mdTypeDef, mdMethodDefOrRef, mdMethodDefOrRef},
+	mdModuleRef:              {mdString},
+	mdTypeSpec:               {mdBlob},
+	mdImplMap:                {mdU16, mdMemberForwarded, mdString, mdModuleRef},
+	mdFieldRVA:               {mdU32, mdField},
+	mdEncLog:                 {mdU32, mdU32},
+	mdEncMap:                 {mdU32},
+	mdAssembly:               {mdU32, mdU16, mdU16, mdU16, mdU16, mdU32, mdBlob, mdString, mdString},
+	mdAssemblyProcessor:      {mdU32},
+	mdAssemblyOS:             {mdU32, mdU32, mdU32},
+	mdAssemblyRef:            {mdU16, mdU16, mdU16, mdU16, mdU32, mdBlob, mdString, mdString, mdBlob},
+	mdAssemblyRefProcessor:   {mdU32, mdAssemblyRef},
+	mdAssemblyRefOS:          {mdU32, mdU32, mdU32, mdAssemblyRef},
+	mdFile:                   {mdU32, mdString, mdBlob},
+	mdExportedType:           {mdU32, mdU32, mdString, mdString, mdImplementation},
+	mdManifestResource:       {mdU32, mdU32, mdString, mdImplementation},
+	mdNestedClass:            {mdTypeDef, mdTypeDef},
+	mdGenericParam:           {mdU16, mdU16, mdTypeOrMethodDef, mdString},
+	mdMethodSpec:             {mdMethodDefOrRef, mdBlob},
+	mdGenericParamConstraint: {mdGenericParam, mdTypeDefOrRef},
+}
+
+type clrStream struct {
+	Name   string
+	Offset uint32
+	Size   uint32
+}
+
+type clrMetadata struct {
+	Version  string
+	Streams  []clrStream
+	Major    uint8
+	Minor    uint8
+	Rows     [mdMaxTable]uint32
+	strings  []byte
+	guids    []byte
+	blobs    []byte
+	tables   []byte
+	heaps    uint8
+	offsets  [mdMaxTable]int
+	rowSize  [mdMaxTable]int
+	colSizes [mdMaxTable][]int
+	owners   []uint32
+	nested   map[uint32]uint32
+}
+
+func readCLRHeader(f *peutil.File) *clrHeader {
+	d := f.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR)
+	if d == nil || d.VirtualAddress == 0 || d.Size == 0 {
+		return nil
+	}
+	b := readRVA(f, d.VirtualAddress, 72)
+	if len(b) < 72 {
+		return nil
+	}
+
+	var h clrHeader
+	binary.Read(bytes.NewReader(b), binary.LittleEndian, &h)
+	return &h
+}
+
+// readMetadata parses the metadata root at the start of the
+// metadata directory and sizes the rows of the table stream
+func readMetadata(f *peutil.File, h *clrHeader) (*clrMetadata, error) {
+	b := readRVA(f, h.MetaData.VirtualAddress, int(h.MetaData.Size))
+	if len(b) < 16 || binary.LittleEndian.Uint32(b) != 0x424a5342 {
+		return nil, fmt.Errorf("invalid metadata signature")
+	}
+
+	m := &clrMetadata{}
+	n := int(binary.LittleEndian.Uint32(b[12:]))
+	if 16+n+4 > len(b) {
+		return nil, fmt.Errorf("metadata version string out of bounds")
+	}
+	m.Version = cstring(b[16 : 16+n])
+	p := b[16+n+2:]
+	nstreams := int(binary.LittleEndian.Uint16(p))
+	p = p[2:]
+	for i := 0; i < nstreams && len(p) >= 8; i++ {
+		s := clrStream{
+			Offset: binary.LittleEndian.Uint32(p),
+			Size:   binary.LittleEndian.Uint32(p[4:]),
+			Name:   cstring(p[8:]),
+		}
+		m.Streams = append(m.Streams, s)
+		p = p[min(len(p), 8+align4(len(s.Name)+1)):]
+
+		if uint64(s.Offset)+uint64(s.Size) > uint64(len(b)) {
+			continue
+		}
+		data := b[s.Offset : s.Offset+s.Size]
+		switch s.Name {
+		case "#~", "#-":
+			m.tables = data
+		case "#Strings":
+			m.strings = data
+		case "#GUID":
+			m.guids = data
+		case "#Blob":
+			m.blobs = data
+		}
+	}
+
+	t := m.tables
+	if len(t) < 24 {
+		return m, nil
+	}
+	m.Major, m.Minor, m.heaps = t[4], t[5], t[6]
+	valid := binary.LittleEndian.Uint64(t[8:])
+	off := 24
+	for i := 0; i < 64; i++ {
+		if valid&(1<<uint(i)) == 0 {
+			continue
+		}
+		if off+4 > len(t) {
+			return nil, fmt.Errorf("metadata table stream truncated")
+		}
+		if i < mdMaxTable {
+			m.Rows[i] = binary.LittleEndian.Uint32(t[off:])
+		}
+		off += 4
+	}
+	// the uncompressed stream may carry an extra dword after the row counts
+	if m.heaps&0x40 != 0 {
+		off += 4
+	}
+
+	for i := 0; i < mdMaxTable; i++ {
+		m.offsets[i] = off
+		for _, col := range mdSchema[i] {
+			n := m.columnSize(col)
+			m.colSizes[i] = append(m.colSizes[i], n)
+			m.rowSize[i] += n
+		}
+		off += m.rowSize[i] * int(m.Rows[i])
+	}
+	if off > len(t) {
+		return nil, fmt.Errorf("metadata tables extend past the table stream")
+	}
+	return m, nil
+}
+
+func (m *clrMetadata) columnSize(col int) int {
+	switch col {
+	case mdU16:
+		return 2
+	case mdU32:
+		return 4
+	case mdString:
+		return 2 + 2*int(m.heaps&1)
+	case mdGUID:
+		return 2 + int(m.heaps&2)
+	case mdBlob:
+		return 2 + int(m.heaps&4)/2
+	}
+
+	if c, found := mdCodedIndexes[col]; found {
+		var rows uint32
+		for _, t := range c.tables {
+			if t >= 0 {
+				rows = max(rows, m.Rows[t])
+			}
+		}
+		if rows < 1<<(16-c.bits) {
+			return 2
+		}
+		return 4
+	}
+	if m.Rows[col] < 1<<16 {
+		return 2
+	}
+	return 4
+}
+
+// row returns the columns of the 1-based row i of a table
+func (m *clrMetadata) row(table int, i uint32) []uint32 {
+	if i == 0 || i > m.Rows[table] {
+		return nil
+	}
+	off := m.offsets[table] + int(i-1)*m.rowSize[table]
+	r := make([]uint32, len(m.colSizes[table]))
+	for j, n := range m.colSizes[table] {
+		if n == 2 {
+			r[j] = uint32(binary.LittleEndian.Uint16(m.tables[off:]))
+		} else {
+			r[j] = binary.LittleEndian.Uint32(m.tables[off:])
+		}
+		off += n
+	}
+	return r
+}
+
+func (m *clrMetadata) str(i uint32) string {
+	if int(i) >= len(m.strings) {
+		return ""
+	}
+	return cstring(m.strings[i:])
+}
+
+// blob decodes the compressed length prefix of a blob heap entry
+func (m *clrMetadata) blob(i uint32) []byte {
+	if int(i) >= len(m.blobs) {
+		return nil
+	}
+	b := m.blobs[i:]
+	var n, hdr int
+	switch {
+	case b[0]&0x80 == 0:
+		n, hdr = int(b[0]), 1
+	case b[0]&0xc0 == 0x80 && len(b) >= 2:
+		n, hdr = int(b[0]&0x3f)<<8|int(b[1]), 2
+	case b[0]&0xe0 == 0xc0 && len(b) >= 4:
+		n, hdr = int(b[0]&0x1f)<<24|int(b[1])<<16|int(b[2])<<8|int(b[3]), 4
+	default:
+		return nil
+	}
+	if hdr+n > len(b) {
+		return nil
+	}
+	return b[hdr : hdr+n]
+}
+
+func (m *clrMetadata) guid(i uint32) string {
+	if i == 0 || int(i)*16 > len(m.guids) {
+		return ""
+	}
+	b := m.guids[(i-1)*16:]
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]),
+		binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16])
+}
+
+// decode splits a coded index into its table and row
+func (m *clrMetadata) decode(kind int, v uint32) (int, uint32) {
+	c := mdCodedIndexes[kind]
+	tag := int(v & (1<<c.bits - 1))
+	if tag >= len(c.tables) {
+		return -1, 0
+	}
+	return c.tables[tag], v >> c.bits
+}
+
+func (m *clrMetadata) typeName(table int, i uint32) string {
+	r := m.row(table, i)
+	switch {
+	case r == nil:
+		return ""
+	case table == mdTypeDef && m.enclosing(i) != 0:
+		// walk the enclosing types iteratively and bounded, a crafted
+		// NestedClass table can form a cycle
+		name := m.str(r[1])
+		for n := 0; n < 64; n++ {
+			j := m.enclosing(i)
+			q := m.row(mdTypeDef, j)
+			if j == 0 || q == nil {
+				break
+			}
+			i, r = j, q
+			name = m.str(r[1]) + "/" + name
+		}
+		if m.str(r[2]) != "" {
+			name = m.str(r[2]) + "." + name
+		}
+		return name
+	case table == mdTypeDef && m.str(r[2]) != "":
+		return m.str(r[2]) + "." + m.str(r[1])
+	case table == mdTypeDef:
+		return m.str(r[1])
+	case table == mdTypeRef && m.str(r[2]) != "":
+		return m.str(r[2]) + "." + m.str(r[1])
+	case table == mdTypeRef:
+		return m.str(r[1])
+	case table == mdModuleRef:
+		return "[" + m.str(r[0]) + "]"
+	case table == mdMethodDef:
+		return m.methodName(i)
+	case table == mdTypeSpec:
+		return fmt.Sprintf("TypeSpec#%d", i)
+	}
+	return ""
+}
+
+// enclosing returns the type a nested type is declared in
+func (m *clrMetadata) enclosing(i uint32) uint32 {
+	if m.nested == nil {
+		m.nested = make(map[uint32]uint32)
+		for j := uint32(1); j <= m.Rows[mdNestedClass]; j++ {
+			r := m.row(mdNestedClass, j)
+			if r[0] != r[1] {
+				m.nested[r[0]] = r[1]
+			}
+		}
+	}
+	return m.nested[i]
+}
+
+// methodOwners maps each method to the type whose method list contains it
+func (m *clrMetadata) methodOwners() []uint32 {
+	if m.owners != nil {
+		return m.owners
+	}
+	owners := make([]uint32, m.Rows[mdMethodDef]+1)
+	for i := uint32(1); i <= m.Rows[mdTypeDef]; i++ {
+		start := m.row(mdTypeDef, i)[5]
+		end := m.Rows[mdMethodDef] + 1
+		if i < m.Rows[mdTypeDef] {
+			end = m.row(mdTypeDef, i+1)[5]
+		}
+		for j := start; j < end && j < uint32(len(owners)); j++ {
+			owners[j] = i
+		}
+	}
+	m.owners = owners
+	return owners
+}
+
+func (m *clrMetadata) methodName(i uint32) string {
+	r := m.row(mdMethodDef, i)
+	if r == nil {
+		return ""
+	}
+	owners := m.methodOwners()
+	if t := owners[i]; t != 0 {
+		return m.typeName(mdTypeDef, t) + "::" + m.str(r[3])
+	}
+	return m.str(r[3])
+}
+
+func (m *clrMetadata) tokenName(tok uint32) string {
+	table, i := int(tok>>24), tok&0xffffff
+	switch table {
+	case mdMethodDef:
+		return m.methodName(i)
+	case mdFile:
+		if r := m.row(mdFile, i); r != nil {
+			return m.str(r[1])
+		}
+	}
+	return ""
+}
+
+type clrPInvoke struct {
+	Method string `json:"method"`
+	DLL    string `json:"dll"`
+	Import string `json:"import"`
+}
+
+func (m *clrMetadata) pinvokes() []clrPInvoke {
+	var r []clrPInvoke
+	for i := uint32(1); i <= m.Rows[mdImplMap]; i++ {
+		p := m.row(mdImplMap, i)
+		table, j := m.decode(mdMemberForwarded, p[1])
+		dll := ""
+		if mr := m.row(mdModuleRef, p[3]); mr != nil {
+			dll = m.str(mr[0])
+		}
+		name := ""
+		if table == mdMethodDef {
+			name = m.methodName(j)
+		}
+		r = append(r, clrPInvoke{name, dll, m.str(p[2])})
+	}
+	return r
+}
+
+func (m *clrMetadata) assemblyRefs() []string {
+	var r []string
+	for i := uint32(1); i <= m.Rows[mdAssemblyRef]; i++ {
+		a := m.row(mdAssemblyRef, i)
+		s := fmt.Sprintf("%s, Version=%d.%d.%d.%d", m.str(a[6]), a[0], a[1], a[2], a[3])
+		if c := m.str(a[7]); c != "" {
+			s += ", Culture=" + c
+		}
+		if k := m.blob(a[5]); len(k) > 0 {
+			s += fmt.Sprintf(", PublicKeyToken=%x", k)
+		}
+		r = append(r, s)
+	}
+	return r
+}
+
+func readJSONCLR(f *peutil.File) *jsonCLR {
+	h := readCLRHeader(f)
+	if h == nil {
+		return nil
+	}
+
+	j := &jsonCLR{
+		RuntimeVersion:    fmt.Sprintf("%d.%d", h.MajorRuntimeVersion, h.MinorRuntimeVersion),
+		Flags:             []string{},
+		EntryPoint:        jsonHex(h.EntryPoint),
+		AssemblyRefs:      []string{},
+		PInvokes:          []clrPInvoke{},
+		ManifestResources: []string{},
+	}
+	for _, c := range clrFlags {
+		if h.Flags&c.bit != 0 {
+			j.Flags = append(j.Flags, c.str)
+		}
+	}
+
+	m, err := readMetadata(f, h)
+	if err != nil {
+		return j
+	}
+	if h.Flags&COMIMAGE_FLAGS_NATIVE_ENTRYPOINT == 0 {
+		j.EntryPointName = m.tokenName(h.EntryPoint)
+	}
+	j.MetadataVersion = m.Version
+	if a := m.row(mdAssembly, 1); a != nil {
+		j.Assembly = fmt.Sprintf("%s, Version=%d.%d.%d.%d", m.str(a[7]), a[1], a[2], a[3], a[4])
+	}
+	j.AssemblyRefs = append(j.AssemblyRefs, m.assemblyRefs()...)
+	j.PInvokes = append(j.PInvokes, m.pinvokes()...)
+	for i := uint32(1); i <= m.Rows[mdManifestResource]; i++ {
+		j.ManifestResources = append(j.ManifestResources, m.str(m.row(mdManifestResource, i)[2]))
+	}
+	return j
+}
+
+func dumpCLR(f *peutil.File) {
+	h := readCLRHeader(f)
+	if h == nil {
+		return
+	}
+
+	fmt.Println("CLR Header:")
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("Runtime Version      : %d.%d\n", h.MajorRuntimeVersion, h.MinorRuntimeVersion)
+	fmt.Printf("Flags                : %#x\n", h.Flags)
+	for _, c := range clrFlags {
+		if h.Flags&c.bit != 0 {
+			fmt.Printf("    %s\n", c.str)
+		}
+	}
+	dirs := []struct {
+		name string
+		d    pe.DataDirectory
+	}{
+		{"Metadata", h.MetaData},
+		{"Resources", h.Resources},
+		{"Strong Name", h.StrongNameSignature},
+		{"Code Manager Table", h.CodeManagerTable},
+		{"VTable Fixups", h.VTableFixups},
+		{"Export Jumps", h.ExportAddressTableJumps},
+		{"Native Header", h.ManagedNativeHeader},
+	}
+	for _, d := range dirs {
+		if d.d.Size != 0 {
+			fmt.Printf("%-20s : %#x - %#x (%d bytes)\n", d.name, d.d.VirtualAddress, d.d.VirtualAddress+d.d.Size, d.d.Size)
+		}
+	}
+
+	m, err := readMetadata(f, h)
+	if h.Flags&COMIMAGE_FLAGS_NATIVE_ENTRYPOINT != 0 {
+		fmt.Printf("Entry Point          : %#x (native)\n", h.EntryPoint)
+	} else if h.EntryPoint != 0 {
+		name := ""
+		if m != nil {
+			name = m.tokenName(h.EntryPoint)
+		}
+		fmt.Printf("Entry Point          : %#08x %s\n", h.EntryPoint, name)
+	}
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("Metadata: %v\n\n", err)
+		return
+	}
+
+	fmt.Printf("Metadata Version     : %s\n", m.Version)
+	fmt.Printf("Table Schema         : %d.%d\n", m.Major, m.Minor)
+	for _, s := range m.Streams {
+		fmt.Printf("Stream %-13s : %#x - %#x (%d bytes)\n", s.Name, s.Offset, s.Offset+s.Size, s.Size)
+	}
+	fmt.Println()
+
+	fmt.Println("Metadata Tables:")
+	for i, n := range m.Rows {
+		if n != 0 {
+			fmt.Printf("%-24s %d\n", mdTableNames[i], n)
+		}
+	}
+	fmt.Println()
+
+	if a := m.row(mdAssembly, 1); a != nil {
+		fmt.Printf("Assembly: %s, Version=%d.%d.%d.%d", m.str(a[7]), a[1], a[2], a[3], a[4])
+		if c := m.str(a[8]); c != "" {
+			fmt.Printf(", Culture=%s", c)
+		}
+		fmt.Printf("\n\n")
+	}
+
+	refs := m.assemblyRefs()
+	fmt.Printf("Assembly References: (%d)\n", len(refs))
+	fmt.Println(strings.Repeat("-", 80))
+	for _, r := range refs {
+		fmt.Println(r)
+	}
+	fmt.Println()
+
+	fmt.Printf("Type Definitions: (%d)\n", m.Rows[mdTypeDef])
+	fmt.Println(strings.Repeat("-", 80))
+	for i := uint32(1); i <= m.Rows[mdTypeDef]; i++ {
+		r := m.row(mdTypeDef, i)
+		ext := ""
+		if table, j := m.decode(mdTypeDefOrRef, r[3]); j != 0 {
+			ext = " : " + m.typeName(table, j)
+		}
+		fmt.Printf("%#08x %#08x %s%s\n", mdTypeDef<<24|i, r[0], m.typeName(mdTypeDef, i), ext)
+	}
+	fmt.Println()
+
+	owners := m.methodOwners()
+	fmt.Printf("Method Definitions: (%d)\n", m.Rows[mdMethodDef])
+	fmt.Println(strings.Repeat("-", 80))
+	for i := uint32(1); i <= m.Rows[mdMethodDef]; i++ {
+		r := m.row(mdMethodDef, i)
+		name := m.str(r[3])
+		if owners[i] != 0 {
+			name = m.typeName(mdTypeDef, owners[i]) + "::" + name
+		}
+		var attrs []string
+		if r[2]&0x2000 != 0 {
+			attrs = append(attrs, "pinvoke")
+		}
+		if r[1]&0x1000 != 0 {
+			attrs = append(attrs, "internalcall")
+		}
+		if mdMethodDef<<24|i == h.EntryPoint && h.Flags&COMIMAGE_FLAGS_NATIVE_ENTRYPOINT == 0 {
+			attrs = append(attrs, "entrypoint")
+		}
+		a := ""
+		if len(attrs) > 0 {
+			a = " [" + strings.Join(attrs, " ") + "]"
+		}
+		rva := ""
+		if r[0] != 0 {
+			rva = fmt.Sprintf("%#x %s", f.ImageBase+uint64(r[0]), sectionName(f, uint64(r[0])))
+		}
+		fmt.Printf("%#08x %-24s %s%s\n", mdMethodDef<<24|i, rva, name, a)
+	}
+	fmt.Println()
+
+	fmt.Printf("Member References: (%d)\n", m.Rows[mdMemberRef])
+	fmt.Println(strings.Repeat("-", 80))
+	for i := uint32(1); i <= m.Rows[mdMemberRef]; i++ {
+		r := m.row(mdMemberRef, i)
+		table, j := m.decode(mdMemberRefParent, r[0])
+		fmt.Printf("%#08x %s::%s\n", mdMemberRef<<24|i, m.typeName(table, j), m.str(r[1]))
+	}
+	fmt.Println()
+
+	pinvokes := m.pinvokes()
+	fmt.Printf("P/Invoke Imports: (%d)\n", len(pinvokes))
+	fmt.Println(strings.Repeat("-", 80))
+	for _, p := range pinvokes {
+		fmt.Printf("%-32s %-40s %s\n", p.DLL, p.Import, p.Method)
+	}
+	fmt.Println()
+
+	fmt.Printf("Manifest Resources: (%d)\n", m.Rows[mdManifestResource])
+	fmt.Println(strings.Repeat("-", 80))
+	for i := uint32(1); i <= m.Rows[mdManifestResource]; i++ {
+		r := m.row(mdManifestResource, i)
+		vis := "public"
+		if r[1]&7 == 2 {
+			vis = "private"
+		}
+		where := ""
+		if table, j := m.decode(mdImplementation, r[3]); j != 0 {
+			where = " in " + m.tokenName(uint32(table)<<24|j)
+			if table == mdAssemblyRef {
+				if a := m.row(mdAssemblyRef, j); a != nil {
+					where = " in " + m.str(a[6])
+				}
+			}
+		} else if b := readRVA(f, h.Resources.VirtualAddress+r[0], 4); len(b) == 4 {
+			where = fmt.Sprintf(" (%d bytes)", binary.LittleEndian.Uint32(b))
+		}
+		fmt.Printf("%#08x %-7s %s%s\n", r[0], vis, m.str(r[2]), where)
+	}
+	fmt.Println()
+}
+
+// peSummary flattens the parts of an image worth comparing into
+// key value pairs per category, so two images can be diffed without
+// the pointer noise a structure dump has
+type peSummary struct {
+	categories []string
+	values     map[string]map[string]string
+}
+
+func (p *peSummary) add(category, key, format string, args ...interface{}) {
+	if p.values[category] == nil {
+		p.categories = append(p.categories, category)
+		p.values[category] = make(map[string]string)
+	}
+	p.values[category][key] = fmt.Sprintf(format, args...)
+}
+
+func summarize(name string) *peSummary {
+	f, err := peutil.Open(name)
+	ck(err)
+	defer f.Close()
+
+	p := &peSummary{values: make(map[string]map[string]string)}
+	for _, c := range []string{"Header", "Sections", "Imports", "Exports", "Resources", "Version Info"} {
+		p.categories = append(p.categories, c)
+		p.values[c] = make(map[string]string)
+	}
+
+	oh := getOptionalInfo(f)
+	p.add("Header", "Machine", "%s", peutil.MachineType(f.Machine))
+	p.add("Header", "Characteristics", "%#x", f.Characteristics)
+	p.add("Header", "Time Date Stamp", "%#x (%s)", f.TimeDateStamp, time.Unix(int64(f.TimeDateStamp), 0).UTC().Format(time.RFC3339))
+	p.add("Header", "Number of Sections", "%d", len(f.Sections))
+	p.add("Header", "Image Base", "%#x", oh.imgbase)
+	p.add("Header", "Entry", "%#x", oh.entry-oh.imgbase)
+	p.add("Header", "Size of Image", "%#x", oh.imgsize)
+	p.add("Header", "File Alignment", "%#x", f.FileAlignment)
+	p.add("Header", "Section Alignment", "%#x", f.SectionAlignment)
+	p.add("Header", "DLL Characteristics", "%#x %s", oh.dllch, strings.Join(dllFlags(oh.dllch), " "))
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		p.add("Header", "Magic", "PE32")
+		p.add("Header", "Linker Version", "%d.%d", h.MajorLinkerVersion, h.MinorLinkerVersion)
+		p.add("Header", "OS Version", "%d.%d", h.MajorOperatingSystemVersion, h.MinorOperatingSystemVersion)
+		p.add("Header", "Subsystem", "%d %d.%d", h.Subsystem, h.MajorSubsystemVersion, h.MinorSubsystemVersion)
+		p.add("Header", "Checksum", "%#x", h.CheckSum)
+		p.add("Header", "Size of Headers", "%#x", h.SizeOfHeaders)
+	case *pe.OptionalHeader64:
+		p.add("Header", "Magic", "PE32+")
+		p.add("Header", "Linker Version", "%d.%d", h.MajorLinkerVersion, h.MinorLinkerVersion)
+		p.add("Header", "OS Version", "%d.%d", h.MajorOperatingSystemVersion, h.MinorOperatingSystemVersion)
+		p.add("Header", "Subsystem", "%d %d.%d", h.Subsystem, h.MajorSubsystemVersion, h.MinorSubsystemVersion)
+		p.add("Header", "Checksum", "%#x", h.CheckSum)
+		p.add("Header", "Size of Headers", "%#x", h.SizeOfHeaders)
+	}
+	for i, name := range dirNames {
+		if d := f.DataDirectory(i); d != nil && d.Size != 0 {
+			p.add("Header", strings.TrimPrefix(name, "IMAGE_DIRECTORY_ENTRY_"), "%#x (%d bytes)", d.VirtualAddress, d.Size)
+		}
+	}
+
+	seen := make(map[string]int)
+	for _, s := range f.Sections {
+		key := s.Name
+		if seen[s.Name]++; seen[s.Name] > 1 {
+			key = fmt.Sprintf("%s#%d", s.Name, seen[s.Name])
+		}
+		p.add("Sections", key+" Virtual Address", "%#x", s.VirtualAddress)
+		p.add("Sections", key+" Virtual Size", "%#x", s.VirtualSize)
+		p.add("Sections", key+" Raw Size", "%#x", s.Size)
+		p.add("Sections", key+" Characteristics", "%#x %s", s.Characteristics, strings.Join(sectionFlags(s.Characteristics), " "))
+		p.add("Sections", key+" SHA256", "%x", sha256.Sum256(s.Data))
+	}
+
+	if f.OptionalHeader != nil {
+		imps := readImports(f)
+		delays := readDelayImports(f)
+		for i, y := range append(imps, delays...) {
+			name := y.Name
+			if y.ByOrdinal {
+				name = fmt.Sprintf("#%d", y.Ordinal)
+			}
+			kind := "import"
+			if i >= len(imps) {
+				kind = "delay import"
+			}
+			p.add("Imports", strings.ToLower(y.DLL)+"!"+name, "%s", kind)
+		}
+
+		for _, y := range readExports(f) {
+			key := y.Name
+			if key == "" {
+				key = fmt.Sprintf("#%d", y.Ordinal)
+			}
+			if y.Forwarder != "" {
+				p.add("Exports", key, "ordinal %d -> %s", y.Ordinal, y.Forwarder)
+			} else {
+				p.add("Exports", key, "ordinal %d", y.Ordinal)
+			}
+		}
+	}
+
+	res, _ := readResources(f)
+	for _, r := range res {
+		p.add("Resources", r.TypeName()+"/"+r.Name.String()+"/"+r.Lang.String(), "%d bytes sha256 %x", r.Size, sha256.Sum256(r.Data))
+		if r.Type.Name != "" || r.Type.ID != RT_VERSION {
+			continue
+		}
+		vi, err := parseVersionInfo(r.Data)
+		if err != nil {
+			continue
+		}
+		p.add("Version Info", "FileVersion", "%s", vi.FileVersion)
+		p.add("Version Info", "ProductVersion", "%s", vi.ProductVersion)
+		p.add("Version Info", "FileFlags", "%#x", vi.FileFlags)
+		for _, lang := range sortedKeys(vi.Strings) {
+			for k, v := range vi.Strings[lang] {
+				p.add("Version Info", lang+" "+k, "%s", v)
+			}
+		}
+	}
+
+	return p
+}
+
+func diff(a, b string) {
+	x := summarize(a)
+	y := summarize(b)
+
+	changed := 0
+	for _, c := range x.categories {
+		xv, yv := x.values[c], y.values[c]
+		keys := make(map[string]bool)
+		for k := range xv {
+			keys[k] = true
+		}
+		for k := range yv {
+			keys[k] = true
+		}
+
+		var lines []string
+		for _, k := range sortedKeys(keys) {
+			u, inx := xv[k]
+			v, iny := yv[k]
+			switch {
+			case inx && !iny:
+				lines = append(lines, fmt.Sprintf("- %s: %s", k, u))
+			case !inx && iny:
+				lines = append(lines, fmt.Sprintf("+ %s: %s", k, v))
+			case u != v:
+				lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", k, u, v))
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		fmt.Printf("%s: (%d changes)\n", c, len(lines))
+		fmt.Println(strings.Repeat("-", 80))
+		for _, l := range lines {
+			fmt.Println(l)
+		}
+		fmt.Println()
+		changed += len(lines)
+	}
+
+	if changed == 0 {
+		fmt.Println("no differences")
+	}
+}
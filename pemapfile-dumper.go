@@ -1,6 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"debug/elf"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -9,6 +14,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/arch/arm/armasm"
@@ -26,18 +33,26 @@ var flags struct {
 	NoDump      bool
 	ShowCallers bool
 	BaseAddr    uint64
+	Graph       string
+	WhoCalls    string
+	Export      string
+	Validate    bool
 }
 
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("pemapfile-dumper: ")
 
-	flag.StringVar(&flags.Arch, "a", runtime.GOARCH, "architecture for assembly analyis features")
+	flag.StringVar(&flags.Arch, "a", runtime.GOARCH, "architecture for assembly analyis features [amd64 | 386 | arm | thumb | arm64]")
 	flag.BoolVar(&flags.ShowCallers, "c", false, "show callers")
 	flag.BoolVar(&flags.NoDump, "nd", false, "don't dump but print the processing output")
 	flag.StringVar(&flags.OutputDir, "o", ".", "output directory")
 	flag.StringVar(&flags.Pattern, "p", ".*", "symbol pattern to match")
-	flag.Uint64Var(&flags.BaseAddr, "b", 0, "custom base address")
+	flag.Uint64Var(&flags.BaseAddr, "b", 0, "image base for computed addresses, unset the dump shows rvas and exports use the exe's image base")
+	flag.StringVar(&flags.Graph, "g", "", "write the call graph of the matched symbols [dot | json]")
+	flag.StringVar(&flags.WhoCalls, "w", "", "show who calls the symbols matching pattern")
+	flag.BoolVar(&flags.Validate, "V", false, "validate the map file against the exe instead of dumping")
+	flag.StringVar(&flags.Export, "e", "", "export the matched symbols to stdout [nm | ghidra | ida | elf | breakpad]")
 	flag.Usage = usage
 	flag.Parse()
 	if flag.NArg() != 2 {
@@ -50,6 +65,15 @@ func main() {
 	mf, err := pemapfile.Open(flag.Arg(1))
 	ck(err)
 
+	if flags.Validate {
+		n, err := validate(pf, mf, flag.Arg(1), os.Stdout)
+		ck(err)
+		if n > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	err = dump(pf, mf, flags.OutputDir, flags.Pattern)
 	ck(err)
 }
@@ -67,7 +91,7 @@ func ck(err error) {
 }
 
 func dump(pf *peutil.File, mf *pemapfile.File, outdir, pat string) error {
-	if !flags.NoDump {
+	if !flags.NoDump && flags.Graph == "" && flags.WhoCalls == "" && flags.Export == "" {
 		os.MkdirAll(outdir, 0755)
 	}
 
@@ -75,33 +99,53 @@ func dump(pf *peutil.File, mf *pemapfile.File, outdir, pat string) error {
 	if err != nil {
 		return err
 	}
+	if flags.Export != "" {
+		return export(pf, mf, re, os.Stdout)
+	}
 
 	w := os.Stdout
+	x := newsymindex(pf, mf)
+	if flags.Graph != "" || flags.WhoCalls != "" {
+		g := buildgraph(x, re)
+		switch flags.Graph {
+		case "":
+		case "dot":
+			writedot(g, w)
+		case "json":
+			b, err := json.MarshalIndent(g, "", "\t")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\n", b)
+		default:
+			return fmt.Errorf("unknown graph format %q", flags.Graph)
+		}
+		if flags.WhoCalls != "" {
+			return whocalls(g, flags.WhoCalls, w)
+		}
+		return nil
+	}
+
 	for i := 0; i < len(mf.Symbols); i++ {
 		y := &mf.Symbols[i]
 		if !re.MatchString(y.Name) {
 			continue
 		}
 
-		ms := &mf.Sections[y.Section]
-		ps := findsect(pf, ms.Name)
+		ps, data := symdata(pf, mf, y)
 		if ps == nil {
 			continue
 		}
-		if y.Offset >= uint64(len(ps.Data)) || y.Offset+y.Size >= uint64(len(ps.Data)) {
-			continue
-		}
 
 		name := filepath.Join(outdir, y.Name)
-		data := ps.Data[y.Offset : y.Offset+y.Size]
 		fmt.Fprintf(w, "%-90q %#016x-%#016x %#016x-%#016x %#08x-%#08x %#08x\n",
 			y.Name,
-			flags.BaseAddr+y.Offset, flags.BaseAddr+y.Offset+y.Size,
+			rebase(pf, y.Segment, y.Offset), rebase(pf, y.Segment, y.Offset+y.Size),
 			y.Addr, y.Addr+y.Size,
 			y.Offset, y.Offset+y.Size, y.Size)
 
 		if flags.ShowCallers {
-			callers(mf, y, data, w)
+			callers(x, ps, i, data, w)
 		}
 
 		if flags.NoDump {
@@ -127,66 +171,1157 @@ func findsect(pf *peutil.File, sect string) *peutil.Section {
 	return nil
 }
 
-func callers(mf *pemapfile.File, sym *pemapfile.Symbol, data []byte, w io.Writer) {
+// segoff converts an rva to the segment number and offset used by the map file
+func segoff(pf *peutil.File, rva uint64) (int, uint64, bool) {
+	for i, s := range pf.Sections {
+		size := uint64(max(s.VirtualSize, s.Size))
+		if uint64(s.VirtualAddress) <= rva && rva < uint64(s.VirtualAddress)+size {
+			return i + 1, rva - uint64(s.VirtualAddress), true
+		}
+	}
+	return 0, 0, false
+}
+
+// literal reads a pointer sized value stored at rva and returns the rva it points to
+func literal(pf *peutil.File, rva uint64, size int) (uint64, bool) {
+	s, _, off := pf.LookupVirtualAddress(rva)
+	if s == nil || off < 0 || off+size > len(s.Data) {
+		return 0, false
+	}
+
+	var v uint64
+	for i := size - 1; i >= 0; i-- {
+		v = v<<8 | uint64(s.Data[off+i])
+	}
+	if flags.Arch == "thumb" {
+		v &^= 1
+	}
+	if v < pf.ImageBase {
+		return 0, false
+	}
+	v -= pf.ImageBase
+	if _, _, ok := segoff(pf, v); !ok {
+		return 0, false
+	}
+	return v, true
+}
+
+// ref is an address an instruction refers to, indirect
+// refs name the slot the target is loaded from
+type ref struct {
+	rva      uint64
+	kind     int
+	indirect bool
+}
+
+const (
+	refCall = iota
+	refJump
+	refAddr
+)
+
+// addrstate tracks registers that are being built up by multi instruction
+// address formation, adrp pages and loaded slots on arm64 and movw halves on thumb
+type addrstate struct {
+	pages map[string]uint64
+	slots map[string]uint64
+	lo    map[uint16]uint64
+}
+
+func decodex86(pf *peutil.File, data []byte, rva uint64) ([]ref, int, error) {
+	bits := 64
+	if flags.Arch == "386" {
+		bits = 32
+	}
+	inst, err := x86asm.Decode(data, bits)
+	if err != nil {
+		return nil, 1, err
+	}
+
+	kind := refCall
+	switch inst.Op {
+	case x86asm.CALL:
+	case x86asm.JMP:
+		kind = refJump
+	default:
+		return nil, inst.Len, nil
+	}
+
+	var refs []ref
+	next := rva + uint64(inst.Len)
+	switch arg := inst.Args[0].(type) {
+	case x86asm.Rel:
+		refs = append(refs, ref{next + uint64(int64(arg)), kind, false})
+	case x86asm.Mem:
+		// call [rip+slot] on amd64 and call [slot] on 386 go through the iat
+		switch {
+		case arg.Base == x86asm.RIP && arg.Index == 0:
+			refs = append(refs, ref{next + uint64(arg.Disp), kind, true})
+		case arg.Base == 0 && arg.Index == 0 && uint64(uint32(arg.Disp)) >= pf.ImageBase:
+			refs = append(refs, ref{uint64(uint32(arg.Disp)) - pf.ImageBase, kind, true})
+		}
+	}
+	return refs, inst.Len, nil
+}
+
+// decodearm handles arm mode, the pc reads as the instruction address plus 8
+func decodearm(pf *peutil.File, data []byte, rva uint64) ([]ref, int, error) {
+	inst, err := armasm.Decode(data, armasm.ModeARM)
+	if err != nil {
+		return nil, 4, err
+	}
+
+	var refs []ref
+	pc := rva + 8
+	switch inst.Op &^ 15 {
+	case armasm.BL_EQ, armasm.BLX_EQ:
+		if arg, ok := inst.Args[0].(armasm.PCRel); ok {
+			refs = append(refs, ref{pc + uint64(int64(arg)), refCall, false})
+		}
+
+	case armasm.LDR_EQ:
+		if m, ok := inst.Args[1].(armasm.Mem); ok && m.Base == armasm.PC && m.Mode == armasm.AddrOffset {
+			if v, ok := literal(pf, pc+uint64(int64(m.Offset)), 4); ok {
+				refs = append(refs, ref{v, refAddr, false})
+			}
+		}
+
+	case armasm.ADD_EQ, armasm.SUB_EQ:
+		imm, ok := inst.Args[2].(armasm.Imm)
+		if r, _ := inst.Args[1].(armasm.Reg); ok && r == armasm.PC {
+			if inst.Op&^15 == armasm.SUB_EQ {
+				imm = -imm
+			}
+			refs = append(refs, ref{pc + uint64(int64(int32(imm))), refAddr, false})
+		}
+	}
+	return refs, 4, nil
+}
+
+// decodethumb handles the thumb-2 instructions that form addresses,
+// x/arch only decodes arm mode so these are matched by encoding
+func decodethumb(pf *peutil.File, st *addrstate, data []byte, rva uint64) ([]ref, int, error) {
+	if len(data) < 2 {
+		return nil, 2, fmt.Errorf("short instruction")
+	}
+
+	var refs []ref
+	pc := rva + 4
+	h1 := uint32(data[0]) | uint32(data[1])<<8
+	switch h1 >> 11 {
+	case 0x09: // ldr rt, [pc, #imm8]
+		if v, ok := literal(pf, pc&^3+uint64(h1&0xff)*4, 4); ok {
+			refs = append(refs, ref{v, refAddr, false})
+		}
+		return refs, 2, nil
+
+	case 0x14: // adr rd, label
+		refs = append(refs, ref{pc&^3 + uint64(h1&0xff)*4, refAddr, false})
+		return refs, 2, nil
+
+	case 0x1d, 0x1e, 0x1f:
+		if len(data) < 4 {
+			return nil, 2, fmt.Errorf("short instruction")
+		}
+
+	default:
+		return nil, 2, nil
+	}
+
+	h2 := uint32(data[2]) | uint32(data[3])<<8
+	switch {
+	case h1>>11 == 0x1e && h2&0xc000 == 0xc000:
+		// bl and blx share the branch offset layout, blx targets arm code
+		s := (h1 >> 10) & 1
+		i1 := ^((h2 >> 13) ^ s) & 1
+		i2 := ^((h2 >> 11) ^ s) & 1
+		imm := s<<24 | i1<<23 | i2<<22 | (h1&0x3ff)<<12 | (h2&0x7ff)<<1
+		off := int64(int32(imm<<7) >> 7)
+		if h2&0x1000 != 0 {
+			refs = append(refs, ref{pc + uint64(off), refCall, false})
+		} else {
+			refs = append(refs, ref{pc&^3 + uint64(off&^3), refCall, false})
+		}
+
+	case h1&0xff7f == 0xf85f: // ldr.w rt, [pc, #+/-imm12]
+		off := int64(h2 & 0xfff)
+		if h1&0x80 == 0 {
+			off = -off
+		}
+		if v, ok := literal(pf, pc&^3+uint64(off), 4); ok {
+			refs = append(refs, ref{v, refAddr, false})
+		}
+
+	case h1&0xfbf0 == 0xf240, h1&0xfbf0 == 0xf2c0: // movw, movt
+		rd := uint16(h2>>8) & 0xf
+		imm := uint64((h1&0xf)<<12 | (h1>>10&1)<<11 | (h2>>12&7)<<8 | h2&0xff)
+		if h1&0xfbf0 == 0xf240 {
+			st.lo[rd] = imm
+		} else if lo, found := st.lo[rd]; found {
+			delete(st.lo, rd)
+			if v := (imm<<16 | lo) &^ 1; v >= pf.ImageBase {
+				if _, _, ok := segoff(pf, v-pf.ImageBase); ok {
+					refs = append(refs, ref{v - pf.ImageBase, refAddr, false})
+				}
+			}
+		}
+	}
+	return refs, 4, nil
+}
+
+// decodearm64 follows adrp pages into the add or load that completes the address,
+// a register loaded from a slot and then branched through is an import call
+func decodearm64(pf *peutil.File, st *addrstate, data []byte, rva uint64) ([]ref, int, error) {
+	inst, err := arm64asm.Decode(data)
+	if err != nil {
+		return nil, 4, err
+	}
+
+	var (
+		refs []ref
+		slot uint64
+	)
+	switch inst.Op {
+	case arm64asm.BL:
+		if arg, ok := inst.Args[0].(arm64asm.PCRel); ok {
+			refs = append(refs, ref{rva + uint64(int64(arg)), refCall, false})
+		}
+
+	case arm64asm.B:
+		if arg, ok := inst.Args[0].(arm64asm.PCRel); ok {
+			refs = append(refs, ref{rva + uint64(int64(arg)), refJump, false})
+		}
+
+	case arm64asm.BLR, arm64asm.BR:
+		if v, found := st.slots[inst.Args[0].String()]; found {
+			kind := refCall
+			if inst.Op == arm64asm.BR {
+				kind = refJump
+			}
+			refs = append(refs, ref{v, kind, true})
+		}
+		return refs, 4, nil
+
+	case arm64asm.ADR:
+		refs = append(refs, ref{rva + uint64(int64(inst.Args[1].(arm64asm.PCRel))), refAddr, false})
+
+	case arm64asm.ADRP:
+		st.pages[inst.Args[0].String()] = rva&^0xfff + uint64(int64(inst.Args[1].(arm64asm.PCRel)))
+		return nil, 4, nil
+
+	case arm64asm.ADD:
+		// the immediate is not exported, so recover it from the #imm[, LSL #n] form
+		page, found := st.pages[inst.Args[1].String()]
+		if _, ok := inst.Args[2].(arm64asm.ImmShift); ok && found {
+			var imm, shift uint64
+			fmt.Sscanf(inst.Args[2].String(), "#%v, LSL #%v", &imm, &shift)
+			refs = append(refs, ref{page + imm<<shift, refAddr, false})
+		}
+
+	case arm64asm.LDR, arm64asm.LDRSW:
+		size := 8
+		if r, ok := inst.Args[0].(arm64asm.Reg); ok && arm64asm.W0 <= r && r <= arm64asm.WZR {
+			size = 4
+		}
+		switch arg := inst.Args[1].(type) {
+		case arm64asm.PCRel:
+			if v, ok := literal(pf, rva+uint64(int64(arg)), size); ok {
+				refs = append(refs, ref{v, refAddr, false})
+			}
+		case arm64asm.MemImmediate:
+			if page, found := st.pages[arg.Base.String()]; found && arg.Mode == arm64asm.AddrOffset {
+				var imm uint64
+				str := arg.String()
+				if i := strings.Index(str, ",#"); i >= 0 {
+					imm, _ = strconv.ParseUint(strings.TrimSuffix(str[i+2:], "]"), 0, 64)
+				}
+				slot = page + imm
+				refs = append(refs, ref{slot, refAddr, false})
+			}
+		}
+	}
+
+	// any other write to a tracked register ends the address formation
+	if len(inst.Args) > 0 && inst.Args[0] != nil {
+		delete(st.pages, inst.Args[0].String())
+		delete(st.slots, inst.Args[0].String())
+		if slot != 0 {
+			st.slots[inst.Args[0].String()] = slot
+		}
+	}
+	return refs, 4, nil
+}
+
+// edge is a reference from a symbol to another symbol or an import,
+// at is the segment offset just past the referencing instruction
+type edge struct {
+	from int
+	to   int
+	at   int64
+	off  uint64
+	kind string
+	imp  string
+}
+
+// symindex orders the map symbols by segment and offset for lookups,
+// and names the iat slots from the pe import table
+type symindex struct {
+	pf   *peutil.File
+	mf   *pemapfile.File
+	syms []int
+	iat  map[uint64]string
+}
+
+func newsymindex(pf *peutil.File, mf *pemapfile.File) *symindex {
+	x := &symindex{
+		pf:  pf,
+		mf:  mf,
+		iat: make(map[uint64]string),
+	}
+	for i := range mf.Symbols {
+		x.syms = append(x.syms, i)
+	}
+	sort.SliceStable(x.syms, func(i, j int) bool {
+		a, b := &mf.Symbols[x.syms[i]], &mf.Symbols[x.syms[j]]
+		if a.Segment != b.Segment {
+			return a.Segment < b.Segment
+		}
+		return a.Offset < b.Offset
+	})
+
+	imps, _ := pf.ReadImportTable()
+	for _, d := range imps {
+		for _, y := range d.Symbols {
+			x.iat[y.ThunkRVA] = d.DLLName + "!" + y.Name
+		}
+	}
+	return x
+}
+
+// lookup returns the symbol containing the segment offset or -1
+func (x *symindex) lookup(seg int, off uint64) int {
+	p := x.mf.Symbols
+	i := sort.Search(len(x.syms), func(i int) bool {
+		y := &p[x.syms[i]]
+		return y.Segment > seg || (y.Segment == seg && y.Offset > off)
+	})
+	for i--; i >= 0; i-- {
+		y := &p[x.syms[i]]
+		if y.Segment != seg {
+			break
+		}
+		if off < y.Offset+y.Size {
+			return x.syms[i]
+		}
+		// zero sized symbols can hide the enclosing one
+		if y.Size != 0 {
+			break
+		}
+	}
+	return -1
+}
+
+// scan decodes the symbol data and returns the symbols and imports it references,
+// jumps only count when they leave the symbol, making them tail calls
+func (x *symindex) scan(ps *peutil.Section, from int, data []byte) []edge {
+	sym := &x.mf.Symbols[from]
+	st := &addrstate{
+		pages: make(map[string]uint64),
+		slots: make(map[string]uint64),
+		lo:    make(map[uint16]uint64),
+	}
+
+	var edges []edge
 	pc := 0
 	for len(data) > 0 {
 		var (
-			rel    int64
+			refs   []ref
 			length int
-			err    error
 		)
 
+		rva := uint64(ps.VirtualAddress) + sym.Offset + uint64(pc)
 		switch flags.Arch {
 		case "amd64", "386":
-			bits := 64
-			if flags.Arch == "386" {
-				bits = 32
-			}
-			var inst x86asm.Inst
-			inst, err = x86asm.Decode(data, bits)
-			length = inst.Len
-			if inst.Op == x86asm.CALL {
-				switch arg := inst.Args[0].(type) {
-				case x86asm.Rel:
-					rel = int64(arg)
-				}
-			}
-
+			refs, length, _ = decodex86(x.pf, data, rva)
 		case "arm":
-			var inst armasm.Inst
-			inst, err = armasm.Decode(data, armasm.ModeARM)
-			length = inst.Len
-
+			refs, length, _ = decodearm(x.pf, data, rva)
+		case "thumb":
+			refs, length, _ = decodethumb(x.pf, st, data, rva)
 		case "arm64":
-			_, err = arm64asm.Decode(data)
-			length = 4
-		}
-		if err != nil {
-			length = 1
+			refs, length, _ = decodearm64(x.pf, st, data, rva)
+		default:
+			length = len(data)
 		}
+		length = min(length, len(data))
 		data = data[length:]
 		pc += length
 
-		if rel != 0 {
-			symoff := int64(sym.Offset) + int64(pc)
-			off := uint64(symoff + rel)
-			for _, y := range mf.Symbols {
-				if y.Segment != sym.Segment {
-					continue
+		symoff := int64(sym.Offset) + int64(pc)
+		for _, r := range refs {
+			kind := [...]string{"call", "jump", "ref"}[r.kind]
+			if r.indirect {
+				if name, found := x.iat[r.rva]; found {
+					edges = append(edges, edge{from, -1, symoff, r.rva, "import", name})
 				}
+				continue
+			}
 
-				if y.Offset <= off && off < y.Offset+y.Size {
-					fmt.Fprintf(w, "    %#016x %#016x %#016x %-90q %#016x-%#016x %#016x-%#016x %#08x-%#08x %#08x\n",
-						int64(flags.BaseAddr)+symoff, symoff,
-						off, y.Name,
-						flags.BaseAddr+y.Offset, flags.BaseAddr+y.Offset+y.Size,
-						y.Addr, y.Addr+y.Size,
-						y.Offset, y.Offset+y.Size, y.Size)
-					break
-				}
+			seg, off, ok := segoff(x.pf, r.rva)
+			if !ok {
+				continue
+			}
+			to := x.lookup(seg, off)
+			if to < 0 || (r.kind == refJump && to == from) {
+				continue
+			}
+			edges = append(edges, edge{from, to, symoff, off, kind, ""})
+		}
+	}
+	return edges
+}
+
+func callers(x *symindex, ps *peutil.Section, from int, data []byte, w io.Writer) {
+	base := int64(flags.BaseAddr) + int64(ps.VirtualAddress)
+	for _, e := range x.scan(ps, from, data) {
+		if e.to < 0 {
+			fmt.Fprintf(w, "    %#016x %#016x %#016x %-90q %s\n",
+				base+e.at, e.at, e.off, e.imp, e.kind)
+			continue
+		}
+
+		y := &x.mf.Symbols[e.to]
+		fmt.Fprintf(w, "    %#016x %#016x %#016x %-90q %#016x-%#016x %#016x-%#016x %#08x-%#08x %#08x %s\n",
+			base+e.at, e.at,
+			e.off, y.Name,
+			rebase(x.pf, y.Segment, y.Offset), rebase(x.pf, y.Segment, y.Offset+y.Size),
+			y.Addr, y.Addr+y.Size,
+			y.Offset, y.Offset+y.Size, y.Size, e.kind)
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// rebase is where a segment offset lands with the image loaded at -b
+func rebase(pf *peutil.File, seg int, off uint64) uint64 {
+	if seg < 1 || seg > len(pf.Sections) {
+		return flags.BaseAddr + off
+	}
+	return flags.BaseAddr + uint64(pf.Sections[seg-1].VirtualAddress) + off
+}
+
+// symdata returns the section data backing a map symbol
+func symdata(pf *peutil.File, mf *pemapfile.File, y *pemapfile.Symbol) (*peutil.Section, []byte) {
+	ms := &mf.Sections[y.Section]
+	ps := findsect(pf, ms.Name)
+	if ps == nil {
+		return nil, nil
+	}
+	if y.Offset >= uint64(len(ps.Data)) || y.Offset+y.Size >= uint64(len(ps.Data)) {
+		return nil, nil
+	}
+	return ps, ps.Data[y.Offset : y.Offset+y.Size]
+}
+
+type graphNode struct {
+	Name   string `json:"name"`
+	Addr   uint64 `json:"addr"`
+	Size   uint64 `json:"size"`
+	Import bool   `json:"import"`
+}
+
+type graphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+}
+
+type callgraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// buildgraph scans every symbol matching the pattern, references
+// that are not calls, tail calls or import calls are left out
+func buildgraph(x *symindex, re *regexp.Regexp) *callgraph {
+	g := &callgraph{
+		Nodes: []graphNode{},
+		Edges: []graphEdge{},
+	}
+	nodes := make(map[string]bool)
+	edges := make(map[graphEdge]int)
+	addnode := func(n graphNode) {
+		if !nodes[n.Name] {
+			nodes[n.Name] = true
+			g.Nodes = append(g.Nodes, n)
+		}
+	}
+	symnode := func(i int) graphNode {
+		y := &x.mf.Symbols[i]
+		return graphNode{Name: y.Name, Addr: rebase(x.pf, y.Segment, y.Offset), Size: y.Size}
+	}
+
+	for i := range x.mf.Symbols {
+		y := &x.mf.Symbols[i]
+		if !re.MatchString(y.Name) {
+			continue
+		}
+		ps, data := symdata(x.pf, x.mf, y)
+		if ps == nil {
+			continue
+		}
+
+		addnode(symnode(i))
+		for _, e := range x.scan(ps, i, data) {
+			ge := graphEdge{From: y.Name, Kind: e.kind}
+			switch {
+			case e.kind == "ref":
+				continue
+			case e.to < 0:
+				ge.To = e.imp
+				addnode(graphNode{Name: e.imp, Addr: e.off, Import: true})
+			default:
+				ge.To = x.mf.Symbols[e.to].Name
+				addnode(symnode(e.to))
+			}
+			if edges[ge] == 0 {
+				g.Edges = append(g.Edges, ge)
+			}
+			edges[ge]++
+		}
+	}
+	for i := range g.Edges {
+		e := &g.Edges[i]
+		e.Count = edges[graphEdge{From: e.From, To: e.To, Kind: e.Kind}]
+	}
+	return g
+}
+
+func writedot(g *callgraph, w io.Writer) {
+	fmt.Fprintf(w, "digraph callgraph {\n")
+	fmt.Fprintf(w, "\tnode [shape=box];\n")
+	for _, n := range g.Nodes {
+		if n.Import {
+			fmt.Fprintf(w, "\t%q [style=dashed];\n", n.Name)
+		}
+	}
+	for _, e := range g.Edges {
+		attr := ""
+		switch e.Kind {
+		case "jump":
+			attr = " [style=dashed]"
+		case "import":
+			attr = " [color=blue]"
+		}
+		fmt.Fprintf(w, "\t%q -> %q%s;\n", e.From, e.To, attr)
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+// whocalls prints the reverse index for the callees matching the pattern
+func whocalls(g *callgraph, pat string, w io.Writer) error {
+	re, err := regexp.Compile(pat)
+	if err != nil {
+		return err
+	}
+
+	rev := make(map[string][]graphEdge)
+	var names []string
+	for _, e := range g.Edges {
+		if !re.MatchString(e.To) {
+			continue
+		}
+		if rev[e.To] == nil {
+			names = append(names, e.To)
+		}
+		rev[e.To] = append(rev[e.To], e)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%q:\n", name)
+		for _, e := range rev[name] {
+			fmt.Fprintf(w, "    %-90q %-6s %d\n", e.From, e.Kind, e.Count)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	return nil
+}
+
+// expsym is a map symbol placed at its load address
+type expsym struct {
+	name string
+	addr uint64
+	rva  uint64
+	size uint64
+	typ  byte
+}
+
+// exportsyms places the matched symbols at the pe's image base, or at -b when given,
+// the map's preferred load address is ignored since images are often rebased
+func exportsyms(pf *peutil.File, mf *pemapfile.File, re *regexp.Regexp) []expsym {
+	base := pf.ImageBase
+	if flags.BaseAddr != 0 {
+		base = flags.BaseAddr
+	}
+
+	var syms []expsym
+	for _, y := range mf.Symbols {
+		if !re.MatchString(y.Name) || y.Segment < 1 || y.Segment > len(pf.Sections) {
+			continue
+		}
+
+		s := pf.Sections[y.Segment-1]
+		ch := s.Characteristics
+		typ := byte('R')
+		switch {
+		case ch&(pe.IMAGE_SCN_CNT_CODE|pe.IMAGE_SCN_MEM_EXECUTE) != 0:
+			typ = 'T'
+		case ch&pe.IMAGE_SCN_CNT_UNINITIALIZED_DATA != 0:
+			typ = 'B'
+		case ch&pe.IMAGE_SCN_MEM_WRITE != 0:
+			typ = 'D'
+		}
+
+		rva := uint64(s.VirtualAddress) + y.Offset
+		syms = append(syms, expsym{y.Name, base + rva, rva, y.Size, typ})
+	}
+	sort.SliceStable(syms, func(i, j int) bool {
+		return syms[i].addr < syms[j].addr
+	})
+	return syms
+}
+
+func export(pf *peutil.File, mf *pemapfile.File, re *regexp.Regexp, w io.Writer) error {
+	syms := exportsyms(pf, mf, re)
+	switch flags.Export {
+	case "nm":
+		for _, y := range syms {
+			fmt.Fprintf(w, "%016x %016x %c %s\n", y.addr, y.size, y.typ, y.name)
+		}
+	case "ghidra":
+		writeghidra(syms, w)
+	case "ida":
+		writeida(syms, w)
+	case "elf":
+		return writeelf(pf, syms, w)
+	case "breakpad":
+		writebreakpad(pf, syms, w)
+	default:
+		return fmt.Errorf("unknown export format %q", flags.Export)
+	}
+	return nil
+}
+
+func writeghidra(syms []expsym, w io.Writer) {
+	fmt.Fprintf(w, "# ghidra script, run from the script manager\n")
+	fmt.Fprintf(w, "from ghidra.program.model.symbol import SourceType\n\n")
+	fmt.Fprintf(w, "def label(addr, name, code):\n")
+	fmt.Fprintf(w, "    a = toAddr(addr)\n")
+	fmt.Fprintf(w, "    if code and getFunctionAt(a) is None:\n")
+	fmt.Fprintf(w, "        createFunction(a, name)\n")
+	fmt.Fprintf(w, "    f = getFunctionAt(a)\n")
+	fmt.Fprintf(w, "    if f is not None:\n")
+	fmt.Fprintf(w, "        f.setName(name, SourceType.IMPORTED)\n")
+	fmt.Fprintf(w, "    else:\n")
+	fmt.Fprintf(w, "        createLabel(a, name, True, SourceType.IMPORTED)\n\n")
+	for _, y := range syms {
+		fmt.Fprintf(w, "label(%#x, %q, %s)\n", y.addr, y.name, pybool(y.typ == 'T'))
+	}
+}
+
+func writeida(syms []expsym, w io.Writer) {
+	fmt.Fprintf(w, "# idapython script, run with file -> script file\n")
+	fmt.Fprintf(w, "import ida_funcs\n")
+	fmt.Fprintf(w, "import ida_name\n\n")
+	fmt.Fprintf(w, "def label(ea, name, code):\n")
+	fmt.Fprintf(w, "    if code:\n")
+	fmt.Fprintf(w, "        ida_funcs.add_func(ea)\n")
+	fmt.Fprintf(w, "    ida_name.set_name(ea, name, ida_name.SN_NOWARN | ida_name.SN_NOCHECK)\n\n")
+	for _, y := range syms {
+		fmt.Fprintf(w, "label(%#x, %q, %s)\n", y.addr, y.name, pybool(y.typ == 'T'))
+	}
+}
+
+func pybool(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
+
+// writeelf writes an elf object with no contents, only nobits sections
+// placed where the pe sections load and a symbol table pointing into them,
+// gdb can load it with add-symbol-file without an address
+func writeelf(pf *peutil.File, syms []expsym, w io.Writer) error {
+	var (
+		machine elf.Machine
+		class   = elf.ELFCLASS64
+	)
+	switch flags.Arch {
+	case "amd64":
+		machine = elf.EM_X86_64
+	case "386":
+		machine, class = elf.EM_386, elf.ELFCLASS32
+	case "arm", "thumb":
+		machine, class = elf.EM_ARM, elf.ELFCLASS32
+	case "arm64":
+		machine = elf.EM_AARCH64
+	default:
+		return fmt.Errorf("unsupported architecture %q", flags.Arch)
+	}
+
+	base := pf.ImageBase
+	if flags.BaseAddr != 0 {
+		base = flags.BaseAddr
+	}
+
+	shstrtab := []byte{0}
+	addstr := func(tab *[]byte, s string) uint32 {
+		off := len(*tab)
+		*tab = append(*tab, s...)
+		*tab = append(*tab, 0)
+		return uint32(off)
+	}
+
+	type shdr struct {
+		name      uint32
+		typ       elf.SectionType
+		flags     elf.SectionFlag
+		addr      uint64
+		off, size uint64
+		link      uint32
+		info      uint32
+		align     uint64
+		entsize   uint64
+	}
+	shdrs := []shdr{{}}
+	for _, s := range pf.Sections {
+		fl := elf.SHF_ALLOC
+		if s.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE != 0 {
+			fl |= elf.SHF_EXECINSTR
+		}
+		if s.Characteristics&pe.IMAGE_SCN_MEM_WRITE != 0 {
+			fl |= elf.SHF_WRITE
+		}
+		shdrs = append(shdrs, shdr{
+			name:  addstr(&shstrtab, s.Name),
+			typ:   elf.SHT_NOBITS,
+			flags: fl,
+			addr:  base + uint64(s.VirtualAddress),
+			size:  uint64(max(s.VirtualSize, s.Size)),
+			align: 1,
+		})
+	}
+
+	strtab := []byte{0}
+	symtab := new(bytes.Buffer)
+	bo := binary.LittleEndian
+	writesym := func(name uint32, info uint8, shndx uint16, value, size uint64) {
+		if class == elf.ELFCLASS64 {
+			binary.Write(symtab, bo, &elf.Sym64{Name: name, Info: info, Shndx: shndx, Value: value, Size: size})
+		} else {
+			binary.Write(symtab, bo, &elf.Sym32{Name: name, Info: info, Shndx: shndx, Value: uint32(value), Size: uint32(size)})
+		}
+	}
+	writesym(0, 0, 0, 0, 0)
+	for _, y := range syms {
+		shndx := uint16(elf.SHN_ABS)
+		for i, s := range pf.Sections {
+			if uint64(s.VirtualAddress) <= y.rva && y.rva < uint64(s.VirtualAddress)+uint64(max(s.VirtualSize, s.Size)) {
+				shndx = uint16(i + 1)
+			}
+		}
+		typ := elf.STT_OBJECT
+		if y.typ == 'T' {
+			typ = elf.STT_FUNC
+		}
+		writesym(addstr(&strtab, y.name), elf.ST_INFO(elf.STB_GLOBAL, typ), shndx, y.addr, y.size)
+	}
+
+	var ehsize, shentsize, symentsize uint64 = 64, 64, elf.Sym64Size
+	if class == elf.ELFCLASS32 {
+		ehsize, shentsize, symentsize = 52, 40, elf.Sym32Size
+	}
+
+	symndx := uint32(len(shdrs))
+	off := ehsize
+	shdrs = append(shdrs, shdr{
+		name:    addstr(&shstrtab, ".symtab"),
+		typ:     elf.SHT_SYMTAB,
+		off:     off,
+		size:    uint64(symtab.Len()),
+		link:    symndx + 1,
+		info:    1,
+		align:   8,
+		entsize: symentsize,
+	})
+	off += uint64(symtab.Len())
+	shdrs = append(shdrs, shdr{
+		name:  addstr(&shstrtab, ".strtab"),
+		typ:   elf.SHT_STRTAB,
+		off:   off,
+		size:  uint64(len(strtab)),
+		align: 1,
+	})
+	off += uint64(len(strtab))
+	shstrndx := len(shdrs)
+	shdrs = append(shdrs, shdr{
+		name:  addstr(&shstrtab, ".shstrtab"),
+		typ:   elf.SHT_STRTAB,
+		off:   off,
+		align: 1,
+	})
+	shdrs[shstrndx].size = uint64(len(shstrtab))
+	off += uint64(len(shstrtab))
+	shoff := (off + 7) &^ 7
+
+	b := new(bytes.Buffer)
+	ident := [elf.EI_NIDENT]byte{0x7f, 'E', 'L', 'F', byte(class), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)}
+	if class == elf.ELFCLASS64 {
+		binary.Write(b, bo, &elf.Header64{
+			Ident:     ident,
+			Type:      uint16(elf.ET_EXEC),
+			Machine:   uint16(machine),
+			Version:   uint32(elf.EV_CURRENT),
+			Shoff:     shoff,
+			Ehsize:    uint16(ehsize),
+			Shentsize: uint16(shentsize),
+			Shnum:     uint16(len(shdrs)),
+			Shstrndx:  uint16(shstrndx),
+		})
+	} else {
+		binary.Write(b, bo, &elf.Header32{
+			Ident:     ident,
+			Type:      uint16(elf.ET_EXEC),
+			Machine:   uint16(machine),
+			Version:   uint32(elf.EV_CURRENT),
+			Shoff:     uint32(shoff),
+			Ehsize:    uint16(ehsize),
+			Shentsize: uint16(shentsize),
+			Shnum:     uint16(len(shdrs)),
+			Shstrndx:  uint16(shstrndx),
+		})
+	}
+	b.Write(symtab.Bytes())
+	b.Write(strtab)
+	b.Write(shstrtab)
+	b.Write(make([]byte, shoff-off))
+
+	for _, h := range shdrs {
+		if class == elf.ELFCLASS64 {
+			binary.Write(b, bo, &elf.Section64{
+				Name: h.name, Type: uint32(h.typ), Flags: uint64(h.flags), Addr: h.addr, Off: h.off,
+				Size: h.size, Link: h.link, Info: h.info, Addralign: h.align, Entsize: h.entsize,
+			})
+		} else {
+			binary.Write(b, bo, &elf.Section32{
+				Name: h.name, Type: uint32(h.typ), Flags: uint32(h.flags), Addr: uint32(h.addr), Off: uint32(h.off),
+				Size: uint32(h.size), Link: h.link, Info: h.info, Addralign: uint32(h.align), Entsize: uint32(h.entsize),
+			})
+		}
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// codeview returns the breakpad debug identifier and pdb name from the rsds debug entry
+func codeview(pf *peutil.File) (string, string) {
+	d := pf.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_DEBUG)
+	if d == nil || d.Size == 0 {
+		return "", ""
+	}
+	s, _, off := pf.LookupVirtualAddress(uint64(d.VirtualAddress))
+	if s == nil || off >= len(s.Data) {
+		return "", ""
+	}
+
+	for p := s.Data[off:min(off+int(d.Size), len(s.Data))]; len(p) >= 28; p = p[28:] {
+		if binary.LittleEndian.Uint32(p[12:]) != 2 {
+			continue
+		}
+		cs, _, coff := pf.LookupVirtualAddress(uint64(binary.LittleEndian.Uint32(p[20:])))
+		if cs == nil || coff+24 > len(cs.Data) {
+			continue
+		}
+		cv := cs.Data[coff:]
+		if string(cv[:4]) != "RSDS" {
+			continue
+		}
+		g := cv[4:20]
+		id := fmt.Sprintf("%08X%04X%04X%X%X", binary.LittleEndian.Uint32(g), binary.LittleEndian.Uint16(g[4:]),
+			binary.LittleEndian.Uint16(g[6:]), g[8:], binary.LittleEndian.Uint32(cv[20:]))
+		name := cv[24:]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		return id, string(name)
+	}
+	return "", ""
+}
+
+// writebreakpad writes a symbol file for minidump_stackwalk, breakpad
+// addresses are relative to the module so -b does not apply
+func writebreakpad(pf *peutil.File, syms []expsym, w io.Writer) {
+	arch := map[string]string{
+		"amd64": "x86_64",
+		"386":   "x86",
+		"arm":   "arm",
+		"thumb": "arm",
+		"arm64": "arm64",
+	}[flags.Arch]
+
+	exe := filepath.Base(flag.Arg(0))
+	id, pdb := codeview(pf)
+	if id == "" {
+		id = strings.Repeat("0", 33)
+	}
+	if pdb == "" {
+		pdb = strings.TrimSuffix(exe, filepath.Ext(exe)) + ".pdb"
+	}
+	pdb = filepath.Base(strings.ReplaceAll(pdb, "\\", "/"))
+
+	var imgsize uint32
+	switch h := pf.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imgsize = h.SizeOfImage
+	case *pe.OptionalHeader64:
+		imgsize = h.SizeOfImage
+	}
+
+	fmt.Fprintf(w, "MODULE windows %s %s %s\n", arch, id, pdb)
+	fmt.Fprintf(w, "INFO CODE_ID %08X%x %s\n", pf.TimeDateStamp, imgsize, exe)
+	for _, y := range syms {
+		if y.typ == 'T' && y.size != 0 {
+			fmt.Fprintf(w, "FUNC %x %x 0 %s\n", y.rva, y.size, y.name)
+		} else {
+			fmt.Fprintf(w, "PUBLIC %x 0 %s\n", y.rva, y.name)
+		}
+	}
+}
+
+// mapheader holds the parts of the map file text that pemapfile does not expose
+type mapheader struct {
+	timestamp uint32
+	hasstamp  bool
+	loadaddr  uint64
+	hasload   bool
+	sections  []mapsection
+}
+
+type mapsection struct {
+	segment int
+	start   uint64
+	length  uint64
+	name    string
+}
+
+func readmapheader(name string) (*mapheader, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &mapheader{}
+	insects := false
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Timestamp is "):
+			_, err := fmt.Sscanf(line, "Timestamp is %x", &h.timestamp)
+			h.hasstamp = err == nil
+		case strings.HasPrefix(line, "Preferred load address is "):
+			_, err := fmt.Sscanf(line, "Preferred load address is %x", &h.loadaddr)
+			h.hasload = err == nil
+		case strings.HasPrefix(line, "Start") && strings.Contains(line, "Length"):
+			insects = true
+		case insects && line == "":
+			insects = len(h.sections) == 0
+		case insects:
+			var s mapsection
+			if _, err := fmt.Sscanf(line, "%x:%x %xH %s", &s.segment, &s.start, &s.length, &s.name); err == nil {
+				h.sections = append(h.sections, s)
 			}
 		}
+	}
+	return h, nil
+}
+
+// readexports returns the named, non forwarded exports of the pe and their rvas
+func readexports(pf *peutil.File) map[string]uint64 {
+	d := pf.DataDirectory(pe.IMAGE_DIRECTORY_ENTRY_EXPORT)
+	if d == nil || d.Size < 40 {
+		return nil
+	}
+	read := func(rva uint64) []byte {
+		s, _, off := pf.LookupVirtualAddress(rva)
+		if s == nil || off >= len(s.Data) {
+			return nil
+		}
+		return s.Data[off:]
+	}
+
+	b := read(uint64(d.VirtualAddress))
+	if len(b) < 40 {
+		return nil
+	}
+	nnames := binary.LittleEndian.Uint32(b[24:])
+	funcs := read(uint64(binary.LittleEndian.Uint32(b[28:])))
+	names := read(uint64(binary.LittleEndian.Uint32(b[32:])))
+	ords := read(uint64(binary.LittleEndian.Uint32(b[36:])))
+
+	exps := make(map[string]uint64)
+	for i := uint32(0); i < nnames && int(4*i+4) <= len(names) && int(2*i+2) <= len(ords); i++ {
+		idx := uint32(binary.LittleEndian.Uint16(ords[2*i:]))
+		if int(4*idx+4) > len(funcs) {
+			continue
+		}
+		rva := binary.LittleEndian.Uint32(funcs[4*idx:])
+		if d.VirtualAddress <= rva && rva < d.VirtualAddress+d.Size {
+			continue
+		}
+
+		name := read(uint64(binary.LittleEndian.Uint32(names[4*i:])))
+		if n := bytes.IndexByte(name, 0); n >= 0 {
+			exps[string(name[:n])] = uint64(rva)
+		}
+	}
+	return exps
+}
+
+// undecorate strips the x86 c and stdcall decorations so
+// map names can be matched against export names
+func undecorate(name string) string {
+	name = strings.TrimPrefix(name, "_")
+	if i := strings.LastIndexByte(name, '@'); i > 0 && !strings.HasPrefix(name, "?") {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			name = name[:i]
+		}
+	}
+	return name
+}
+
+// mergetargets are the sections link merges other sections into by default
+var mergetargets = map[string]bool{
+	".text":  true,
+	".rdata": true,
+	".data":  true,
+}
+
+// validate checks that the map file describes this build of the exe,
+// it prints every problem found and returns how many there were
+func validate(pf *peutil.File, mf *pemapfile.File, mapname string, w io.Writer) (int, error) {
+	h, err := readmapheader(mapname)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	problem := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, format+"\n", args...)
+		n++
+	}
+
+	fmt.Fprintf(w, "Header:\n")
+	if !h.hasload {
+		problem("    map has no preferred load address")
+	} else if h.loadaddr != pf.ImageBase {
+		problem("    preferred load address %#x does not match image base %#x", h.loadaddr, pf.ImageBase)
+	}
+	if !h.hasstamp {
+		problem("    map has no timestamp")
+	} else if h.timestamp != pf.TimeDateStamp {
+		problem("    timestamp %#08x does not match exe timestamp %#08x", h.timestamp, pf.TimeDateStamp)
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "Sections:\n")
+	for _, ms := range h.sections {
+		if ms.segment < 1 || ms.segment > len(pf.Sections) {
+			problem("    %04x:%08x %-24s segment does not exist in the exe", ms.segment, ms.start, ms.name)
+			continue
+		}
+		ps := pf.Sections[ms.segment-1]
+		// link merges .bss, .idata, .CRT, .xdata and friends into the default
+		// segments, a different name is only worth a note elsewhere
+		if !strings.HasPrefix(ms.name, ps.Name) && !mergetargets[ps.Name] {
+			fmt.Fprintf(w, "    %04x:%08x %-24s is merged into section %s\n", ms.segment, ms.start, ms.name, ps.Name)
+		}
+		if size := uint64(max(ps.VirtualSize, ps.Size)); ms.start+ms.length > size {
+			problem("    %04x:%08x %-24s ends at %#x past the section size %#x", ms.segment, ms.start, ms.name, ms.start+ms.length, size)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "Symbols:\n")
+	syms := make([]*pemapfile.Symbol, 0, len(mf.Symbols))
+	for i := range mf.Symbols {
+		y := &mf.Symbols[i]
+		if y.Segment == 0 {
+			continue
+		}
+		syms = append(syms, y)
+		if y.Segment > len(pf.Sections) {
+			problem("    %04x:%08x %-40q segment does not exist in the exe", y.Segment, y.Offset, y.Name)
+			continue
+		}
+		ps := pf.Sections[y.Segment-1]
+		size := uint64(max(ps.VirtualSize, ps.Size))
+		switch {
+		case y.Offset >= size:
+			problem("    %04x:%08x %-40q is outside of section %s", y.Segment, y.Offset, y.Name, ps.Name)
+		case y.Offset+y.Size > size:
+			problem("    %04x:%08x %-40q runs %#x bytes past the end of section %s", y.Segment, y.Offset, y.Name, y.Offset+y.Size-size, ps.Name)
+		}
+		if h.hasload && y.Addr != h.loadaddr+uint64(ps.VirtualAddress)+y.Offset {
+			problem("    %04x:%08x %-40q address %#x does not match the section layout %#x", y.Segment, y.Offset, y.Name, y.Addr, h.loadaddr+uint64(ps.VirtualAddress)+y.Offset)
+		}
+	}
 
+	sort.SliceStable(syms, func(i, j int) bool {
+		if syms[i].Segment != syms[j].Segment {
+			return syms[i].Segment < syms[j].Segment
+		}
+		return syms[i].Offset < syms[j].Offset
+	})
+	for i := 1; i < len(syms); i++ {
+		a, b := syms[i-1], syms[i]
+		if a.Segment == b.Segment && a.Offset != b.Offset && a.Offset+a.Size > b.Offset {
+			problem("    %04x:%08x %-40q overlaps %q at %04x:%08x", a.Segment, a.Offset, a.Name, b.Name, b.Segment, b.Offset)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "Exports:\n")
+	byname := make(map[string]*pemapfile.Symbol)
+	for _, y := range syms {
+		byname[y.Name] = y
+		if u := undecorate(y.Name); byname[u] == nil {
+			byname[u] = y
+		}
+	}
+	exps := readexports(pf)
+	names := make([]string, 0, len(exps))
+	for name := range exps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rva := exps[name]
+		y := byname[name]
+		if y == nil {
+			y = byname[undecorate(name)]
+		}
+		switch {
+		case y == nil:
+			problem("    %-40q %#08x is not in the map", name, rva)
+		case y.Segment > len(pf.Sections):
+		case uint64(pf.Sections[y.Segment-1].VirtualAddress)+y.Offset != rva:
+			problem("    %-40q %#08x is at %#08x in the map", name, rva, uint64(pf.Sections[y.Segment-1].VirtualAddress)+y.Offset)
+		}
 	}
 	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "%d problems\n", n)
+	return n, nil
 }